@@ -0,0 +1,48 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// ListPendingApprovals returns the jobs waiting for an approval to start.
+func (rdc *RDClient) ListPendingApprovals(ctx context.Context) ([]*Job, error) {
+	jobs, err := rdc.ListJobs(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var pending []*Job
+	for _, job := range jobs {
+		if StatusExitCode(job.Status) == ExitAwaitingApproval {
+			pending = append(pending, job)
+		}
+	}
+	return pending, nil
+}
+
+// ApproveJob approves a job waiting for an approval and returns its details.
+// The comment is optional.
+func (rdc *RDClient) ApproveJob(ctx context.Context, jobId, comment string) (JobDetails, error) {
+	return rdc.reviewJob(ctx, "approve", jobId, comment)
+}
+
+// RejectJob rejects a job waiting for an approval and returns its details.
+// The comment is optional.
+func (rdc *RDClient) RejectJob(ctx context.Context, jobId, comment string) (JobDetails, error) {
+	return rdc.reviewJob(ctx, "reject", jobId, comment)
+}
+
+func (rdc *RDClient) reviewJob(ctx context.Context, action, jobId, comment string) (JobDetails, error) {
+	relUrl := "deployment/" + action + "/job/" + jobId
+	if comment != "" {
+		relUrl += "?comment=" + url.QueryEscape(comment)
+	}
+	resData, _, err := rdc.call(ctx, http.MethodPut, relUrl, nil, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+	return rdc.jobDetails(resData)
+}
@@ -0,0 +1,324 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+// Package client provides a Go client for the RapidDeploy REST API.
+//
+// Every operation returns its result together with an error and never
+// terminates the calling program, so the package can be used from other
+// Go programs as well as from the 'rd' command line interface.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Default maximum duration of a call retrieving or updating metadata.
+	DefaultTimeout = 30 * time.Second
+	// Default maximum duration of a call transferring files, e.g. project exports and imports.
+	DefaultTransferTimeout = 30 * time.Minute
+	// Default maximum duration to establish the connection with the server.
+	DefaultConnectTimeout = 5 * time.Second
+)
+
+// Path of the call creating an authentication token, whose response is the token.
+const createTokenPath = "user/create/token"
+
+// Temporary authentication token set while a new one is created.
+const tokenPlaceholder = "token"
+
+// ONLY for development purposes.
+// Should always be 'false' by default.
+var genXML bool = false
+
+type (
+	// The client for the REST calls to RapidDeploy.
+	RDClient struct {
+		// URL and authentication token used to perform the calls.
+		// These parameters will be saved as a JSON file in the home folder.
+		// The username and password are never set by current code — the fields
+		// only remain so login files written by older versions can be detected
+		// and scrubbed on load.
+		BaseUrl   *url.URL `json:"url"`
+		AuthToken string   `json:"token"`
+		Username  string   `json:"param1,omitempty"`
+		Password  string   `json:"param2,omitempty"`
+
+		// TLS configuration for HTTPS servers, saved along with the session.
+		TLS *TLSOptions `json:"tls,omitempty"`
+
+		// Maximum durations of the calls. A zero value means the corresponding
+		// default is used and a negative value disables the limit.
+		// They must be set before the first call is performed.
+		Timeout         time.Duration `json:"-"`
+		TransferTimeout time.Duration `json:"-"`
+		ConnectTimeout  time.Duration `json:"-"`
+
+		// Number of times a failed idempotent call is retried and maximum
+		// duration to wait between retries. A zero value means the corresponding
+		// default is used. A negative number of retries disables them and a
+		// negative maximum wait means the default too.
+		Retries      int           `json:"-"`
+		RetryMaxWait time.Duration `json:"-"`
+
+		// If not nil, every call is logged here: its request ID, method, URL,
+		// status and latency at debug level, and its headers and bodies at
+		// LevelTrace. Retries and calls failing to reach the server are warnings.
+		Logger *slog.Logger `json:"-"`
+		// If not nil, the secrets used by the client are registered in it and
+		// masked in the logs.
+		Redactor *Redactor `json:"-"`
+
+		httpClient *http.Client
+	}
+)
+
+// NewRDClient returns a client for the RapidDeploy server at 'rdUrl'
+// that authenticates with the given token.
+func NewRDClient(rdUrl, authToken string) (*RDClient, error) {
+	baseUrl, err := url.Parse(rdUrl)
+	if err != nil {
+		return nil, err
+	}
+	return &RDClient{BaseUrl: baseUrl, AuthToken: authToken}, nil
+}
+
+// Login requests an authentication token for the given credentials and
+// returns a client using it once the connection has been checked.
+func Login(ctx context.Context, rdUrl, username, password string) (*RDClient, error) {
+	rdc, err := NewRDClient(rdUrl, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := rdc.CreateToken(ctx, username, password); err != nil {
+		return nil, err
+	}
+	if err := rdc.CheckConnection(ctx); err != nil {
+		return nil, err
+	}
+	return rdc, nil
+}
+
+// CreateToken requests a new authentication token for the given
+// credentials and sets it in the client.
+func (rdc *RDClient) CreateToken(ctx context.Context, username, password string) error {
+	// This is necessary so an error is not thrown for empty authentication token
+	rdc.AuthToken = tokenPlaceholder
+	rdc.Redactor.AddSecret(password)
+
+	// The credentials are sent in a dedicated request struct so they never
+	// reach the persisted RDClient struct. The 'param1'/'param2' keys must
+	// always be present in the request body, even when the values are empty,
+	// otherwise the server fails with an internal error.
+	reqData, err := json.Marshal(struct {
+		BaseUrl   *url.URL `json:"url"`
+		AuthToken string   `json:"token"`
+		Username  string   `json:"param1"`
+		Password  string   `json:"param2"`
+	}{rdc.BaseUrl, rdc.AuthToken, username, password})
+	if err != nil {
+		return err
+	}
+	resData, _, err := rdc.call(ctx, http.MethodPost, createTokenPath, reqData, "text/plain")
+	if err != nil {
+		rdc.AuthToken = ""
+		return err
+	}
+	rdc.AuthToken = string(resData)
+	rdc.Redactor.AddSecret(rdc.AuthToken)
+	return nil
+}
+
+// CheckConnection checks the URL and the authentication token of the
+// client are valid.
+func (rdc *RDClient) CheckConnection(ctx context.Context) error {
+	// FIXME: to check connection use 'listGroups' until we create a generic web service call!
+	_, _, err := rdc.call(ctx, http.MethodGet, "group/list", nil, "text/xml")
+	return err
+}
+
+// Performs a call to the RapidDeploy web services retrieving or updating metadata.
+// An error is returned if the server cannot be reached or if it answers with a
+// status code other than 200, see 'decodeResponse' for the types of error.
+func (rdc *RDClient) call(ctx context.Context, method string, relUrl string, bodyContent []byte, contentType string) ([]byte, int, error) {
+	return rdc.send(ctx, limit(rdc.Timeout, DefaultTimeout), method, relUrl, bodyContent, contentType)
+}
+
+// Performs a call to the RapidDeploy web services transferring a file,
+// which is allowed to take longer than the calls retrieving metadata.
+func (rdc *RDClient) transfer(ctx context.Context, method string, relUrl string, bodyContent []byte, contentType string) ([]byte, int, error) {
+	return rdc.send(ctx, limit(rdc.TransferTimeout, DefaultTransferTimeout), method, relUrl, bodyContent, contentType)
+}
+
+func (rdc *RDClient) send(ctx context.Context, timeout time.Duration, method string, relUrl string, bodyContent []byte, contentType string) ([]byte, int, error) {
+	if rdc.BaseUrl == nil {
+		return nil, -1, fmt.Errorf("No URL found in login session.\nPlease, perform a new login before requesting any action.")
+	}
+
+	if rdc.AuthToken == "" {
+		return nil, -1, fmt.Errorf("No authentication token found in login session.\nPlease, perform a new login before requesting any action.")
+	}
+
+	// Resolve the absolute URL for the request
+	reqUrl, err := rdc.BaseUrl.Parse(rdc.BaseUrl.EscapedPath() + "/ws/" + relUrl)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	header := make(map[string]string)
+	// Set the headers of the request
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	header["Content-Type"] = contentType
+	header["Authorization"] = rdc.AuthToken
+	requestId := newRequestId()
+	header[requestIdHeader] = requestId
+
+	if rdc.AuthToken != tokenPlaceholder {
+		rdc.Redactor.AddSecret(rdc.AuthToken)
+	}
+
+	// Fail early on a wrong TLS configuration, which retrying would not fix
+	if _, err := rdc.client(); err != nil {
+		return nil, -1, err
+	}
+
+	retries := rdc.Retries
+	if retries == 0 {
+		retries = DefaultRetries
+	}
+	maxWait := rdc.RetryMaxWait
+	if maxWait <= 0 {
+		maxWait = DefaultRetryMaxWait
+	}
+
+	var resData []byte
+	var statusCode int
+	for attempt := 0; ; attempt++ {
+		var resHeader http.Header
+		start := time.Now()
+		resData, statusCode, resHeader, err = rdc.do(ctx, timeout, method, reqUrl.String(), bodyContent, header)
+		rdc.logCall(ctx, requestId, method, reqUrl.String(), attempt, statusCode, time.Since(start), err)
+		if attempt >= retries || !retryable(ctx, method, statusCode, err) {
+			break
+		}
+		wait := retryWait(attempt, resHeader, maxWait)
+		rdc.log(ctx, slog.LevelWarn, "Retrying call", "request_id", requestId, "wait", wait, "retry", attempt+1, "retries", retries)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, -1, ctx.Err()
+		}
+	}
+	if err != nil {
+		return nil, -1, err
+	}
+	return resData, statusCode, decodeResponse(rdc.BaseUrl.String(), statusCode, resData)
+}
+
+// Performs a single HTTP request, limited to 'timeout' if it is greater than zero.
+func (rdc *RDClient) do(ctx context.Context, timeout time.Duration, method string, reqUrlStr string, bodyContent []byte, header map[string]string) ([]byte, int, http.Header, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Create the HTTP request
+	req, err := http.NewRequestWithContext(ctx, method, reqUrlStr, bytes.NewBuffer(bodyContent))
+	if err != nil {
+		return nil, -1, nil, err
+	}
+
+	// Add header to the request
+	for key, value := range header {
+		req.Header.Add(key, value)
+	}
+
+	requestId := header[requestIdHeader]
+	rdc.log(ctx, LevelTrace, "HTTP request header", "request_id", requestId, "header", fmt.Sprint(req.Header))
+	rdc.log(ctx, LevelTrace, "HTTP request body", "request_id", requestId, "body", string(bodyContent))
+
+	// Perform the request
+	httpClient, err := rdc.client()
+	if err != nil {
+		return nil, -1, nil, err
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, -1, nil, err
+	}
+	defer res.Body.Close()
+
+	// Read the response
+	resData, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, -1, nil, err
+	}
+
+	if rdc.Redactor != nil && res.StatusCode == http.StatusOK && strings.HasSuffix(req.URL.Path, "/ws/"+createTokenPath) {
+		// The new authentication token, not registered yet
+		rdc.log(ctx, LevelTrace, "HTTP response body", "request_id", requestId, "body", Mask)
+	} else {
+		rdc.log(ctx, LevelTrace, "HTTP response body", "request_id", requestId, "body", string(resData))
+	}
+
+	// This is just for development purposes.
+	// It will genenerate a file with the XML response
+	// to use afterwards to generate the Go lang structs.
+	// Make sure 'genXML' is always 'false' by default!
+	if genXML {
+		ioutil.WriteFile("aux.xml", resData, 0600)
+	}
+
+	return resData, res.StatusCode, res.Header, nil
+}
+
+// Guards the creation of the HTTP clients, as a client may be used by several goroutines.
+var httpClientMu sync.Mutex
+
+// Returns the HTTP client used to perform the calls, creating it on first use.
+// The duration of each call is limited through its context, so the client
+// itself only limits the time to establish the connection.
+func (rdc *RDClient) client() (*http.Client, error) {
+	httpClientMu.Lock()
+	defer httpClientMu.Unlock()
+	if rdc.httpClient == nil {
+		dialer := &net.Dialer{Timeout: limit(rdc.ConnectTimeout, DefaultConnectTimeout)}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = dialer.DialContext
+		transport.TLSHandshakeTimeout = dialer.Timeout
+		if rdc.TLS != nil {
+			tlsConfig, err := rdc.TLS.config()
+			if err != nil {
+				return nil, err
+			}
+			transport.TLSClientConfig = tlsConfig
+		}
+		rdc.httpClient = &http.Client{Transport: transport}
+	}
+	return rdc.httpClient, nil
+}
+
+// Returns the duration to use for a limit: the default if it is not set
+// and zero, meaning no limit, if it is negative.
+func limit(value, defaultValue time.Duration) time.Duration {
+	if value == 0 {
+		return defaultValue
+	}
+	if value < 0 {
+		return 0
+	}
+	return value
+}
@@ -0,0 +1,432 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const jobResponse = `<html><head><title>Job</title></head><body>
+<div><h2>Header</h2></div>
+<div><div><ul>
+<li><span>Deployment Job ID</span><span>1234</span></li>
+<li><span>Deployment Job Status</span><span>EXECUTING</span></li>
+<li><span>Log File Path</span><span>/opt/rd/logs/job-1234.log</span></li>
+</ul></div></div>
+</body></html>`
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *RDClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	rdc, err := NewRDClient(server.URL+"/MidVision", "tok123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rdc
+}
+
+func TestListProjects(t *testing.T) {
+	rdc := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/MidVision/ws/project/list" || r.Header.Get("Authorization") != "tok123" {
+			t.Errorf("unexpected request: %v %v", r.URL.Path, r.Header)
+		}
+		w.Write([]byte(`<Projects><Project><name>app</name><description>My app</description></Project></Projects>`))
+	})
+	projects, err := rdc.ListProjects(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 1 || projects[0].Name != "app" || projects[0].Description != "My app" {
+		t.Fatalf("unexpected projects: %+v", projects)
+	}
+}
+
+func TestResponseError(t *testing.T) {
+	rdc := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	_, err := rdc.ListServers(context.Background())
+	var unauthorizedErr *UnauthorizedError
+	var resErr *ResponseError
+	if !errors.As(err, &unauthorizedErr) || !errors.As(err, &resErr) || resErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected a 401 unauthorized error, got %v", err)
+	}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	notFound := `<html><body><div/><div><div><ul><li><span>Error</span><span>No entity found for query</span></li></ul></div></div></body></html>`
+	invalid := `<html><body><div/><div><div><ul><li><span>packageName</span><span>Invalid package</span></li></ul></div></div></body></html>`
+
+	var notFoundErr *NotFoundError
+	if err := decodeResponse("url", 400, []byte(notFound)); !errors.As(err, &notFoundErr) {
+		t.Fatalf("expected a not found error, got %#v", err)
+	}
+	var validationErr *ValidationError
+	if err := decodeResponse("url", 400, []byte(invalid)); !errors.As(err, &validationErr) ||
+		len(validationErr.Fields) != 1 || validationErr.Fields[0].Field != "packageName" {
+		t.Fatalf("expected a validation error with one field, got %#v", err)
+	}
+	// Unexpected shapes must not panic and are classified by status code only
+	var serverErr *ServerError
+	for _, body := range []string{"", "not xml", "<html/>", "<html><body><div/><div/></body></html>"} {
+		if err := decodeResponse("url", 502, []byte(body)); !errors.As(err, &serverErr) {
+			t.Fatalf("expected a server error for body %q, got %#v", body, err)
+		}
+	}
+	if err := decodeResponse("url", 200, nil); err != nil {
+		t.Fatalf("expected no error for a successful response, got %v", err)
+	}
+}
+
+func TestGetJob(t *testing.T) {
+	rdc := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(jobResponse))
+	})
+	job, err := rdc.GetJob(context.Background(), "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Id() != "1234" || job.Status() != "EXECUTING" || job.LogFilename() != "job-1234.log" {
+		t.Fatalf("unexpected job details: %q %q %q", job.Id(), job.Status(), job.LogFilename())
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	rdc := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	rdc.Timeout = 50 * time.Millisecond
+	rdc.Retries = -1
+	if _, err := rdc.ListProjects(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the call to time out, got %v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	calls := 0
+	rdc := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(jobResponse))
+	})
+	if _, err := rdc.GetJob(context.Background(), "1234"); err != nil || calls != 3 {
+		t.Fatalf("expected the call to succeed after 2 retries, got %v after %v calls", err, calls)
+	}
+
+	// Starting a deployment is not idempotent, so it must never be retried
+	calls = 0
+	if _, err := rdc.Deploy(context.Background(), "app", "server.install.config", "", nil, time.Time{}); err == nil || calls != 1 {
+		t.Fatalf("expected the deployment to fail without retries, got %v after %v calls", err, calls)
+	}
+}
+
+func TestRetryableErrors(t *testing.T) {
+	ctx := context.Background()
+	refused := &url.Error{Op: "Get", URL: "url", Err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}
+	reset := &url.Error{Op: "Get", URL: "url", Err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}
+	timeout := &url.Error{Op: "Get", URL: "url", Err: context.DeadlineExceeded}
+	certErr := &url.Error{Op: "Get", URL: "url", Err: &tls.CertificateVerificationError{Err: errors.New("unknown authority")}}
+	for err, expected := range map[error]bool{refused: false, reset: true, timeout: true, certErr: false, &PinError{}: false} {
+		if retryable(ctx, http.MethodGet, -1, err) != expected {
+			t.Fatalf("expected retryable %v for %v", expected, err)
+		}
+	}
+
+	// A negative maximum wait uses the default one instead of not waiting
+	calls := 0
+	rdc := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(jobResponse))
+	})
+	rdc.RetryMaxWait = -time.Second
+	start := time.Now()
+	if _, err := rdc.GetJob(ctx, "1234"); err != nil || time.Since(start) < retryBaseWait/2 {
+		t.Fatalf("expected to wait before the retry, got %v after %v", err, time.Since(start))
+	}
+}
+
+func TestTLSOptions(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<Projects/>`))
+	}))
+	defer server.Close()
+	caCertFile := filepath.Join(t.TempDir(), "ca.pem")
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caCertFile, caCert, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	newClient := func(options *TLSOptions) *RDClient {
+		rdc, _ := NewRDClient(server.URL, "tok123")
+		rdc.TLS = options
+		rdc.Retries = -1
+		return rdc
+	}
+	if _, err := newClient(nil).ListProjects(context.Background()); err == nil {
+		t.Fatal("expected an untrusted certificate to be rejected")
+	}
+	if _, err := newClient(&TLSOptions{CACertFile: caCertFile}).ListProjects(context.Background()); err != nil {
+		t.Fatalf("expected the custom CA to be trusted, got %v", err)
+	}
+	pin := "sha256//" + PublicKeyPin(server.Certificate())
+	if _, err := newClient(&TLSOptions{CACertFile: caCertFile, PinnedPublicKeys: []string{pin}}).ListProjects(context.Background()); err != nil {
+		t.Fatalf("expected the pinned public key to be accepted, got %v", err)
+	}
+	var pinErr *PinError
+	_, err := newClient(&TLSOptions{InsecureSkipVerify: true, PinnedPublicKeys: []string{"bm90IGEgcGlu"}}).ListProjects(context.Background())
+	if !errors.As(err, &pinErr) {
+		t.Fatalf("expected a pin error even without certificate verification, got %v", err)
+	}
+}
+
+func TestPinnedPublicKeysOfExtraCertificates(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<Projects/>`))
+	}))
+	defer server.Close()
+	// The server also sends a certificate which is not part of its chain
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "Pinned CA"},
+		NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour), IsCA: true, BasicConstraintsValid: true}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extra, _ := x509.ParseCertificate(der)
+	server.TLS.Certificates[0].Certificate = append(server.TLS.Certificates[0].Certificate, der)
+
+	caCertFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caCertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	pins := []string{PublicKeyPin(extra)}
+	for _, options := range []*TLSOptions{{CACertFile: caCertFile, PinnedPublicKeys: pins}, {InsecureSkipVerify: true, PinnedPublicKeys: pins}} {
+		rdc, _ := NewRDClient(server.URL, "tok123")
+		rdc.TLS, rdc.Retries = options, -1
+		var pinErr *PinError
+		if _, err := rdc.ListProjects(context.Background()); !errors.As(err, &pinErr) {
+			t.Fatalf("expected the pin of a certificate outside the chain to be rejected with %+v, got %v", options, err)
+		}
+	}
+}
+
+func TestListJobs(t *testing.T) {
+	rdc := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/MidVision/ws/deployment/job/list" || r.URL.Query().Get("projectName") != "my app" {
+			t.Errorf("unexpected request: %v", r.URL)
+		}
+		w.Write([]byte(`<DeploymentJobs><DeploymentJob><id>42</id><status>FAILED</status></DeploymentJob></DeploymentJobs>`))
+	})
+	jobs, err := rdc.ListJobs(context.Background(), "my app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Id != 42 || IsSuccessful(jobs[0].Status) || !IsFinished(jobs[0].Status) {
+		t.Fatalf("unexpected jobs: %+v", jobs)
+	}
+	if IsFinished(StatusRequested) || IsSuccessful(StatusExecuting) || !IsSuccessful(StatusCompleted) || IsSuccessful("") || IsSuccessful("PAUSED") {
+		t.Fatal("unexpected classification of the job statuses")
+	}
+}
+
+func TestGetJobPlanRun(t *testing.T) {
+	rdc := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/MidVision/ws/deployment/display/job/1234":
+			w.Write([]byte(strings.Replace(jobResponse, "EXECUTING", "COMPLETED", 1)))
+		case "/MidVision/ws/deployment/jobPlan/run/1234/jobs":
+			w.Write([]byte(`<DeploymentJobs><DeploymentJob><id>1</id><status>COMPLETED</status></DeploymentJob>` +
+				`<DeploymentJob><id>2</id><status>EXECUTING</status></DeploymentJob></DeploymentJobs>`))
+		default:
+			t.Errorf("unexpected request: %v", r.URL)
+		}
+	})
+	run, err := rdc.GetJobPlanRun(context.Background(), "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.JobId != "1234" || len(run.Jobs) != 2 || run.Finished() || run.Successful() {
+		t.Fatalf("unexpected job plan run: %+v", run)
+	}
+	run.Jobs[1].Status = StatusCompleted
+	if !run.Finished() || !run.Successful() {
+		t.Fatalf("expected the job plan run to be successful: %+v", run)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	resErr := &ResponseError{Url: "url", StatusCode: 404}
+	for err, expected := range map[error]int{
+		nil:                        ExitOK,
+		errors.New("boom"):         ExitError,
+		context.Canceled:           ExitInterrupted,
+		context.DeadlineExceeded:   ExitTimeout,
+		&NotFoundError{resErr}:     ExitNotFound,
+		&UnauthorizedError{resErr}: ExitUnauthorized,
+		&ServerError{resErr}:       ExitServerError,
+		&url.Error{Err: errors.New("connection refused")}: ExitConnectionError,
+		&url.Error{Err: os.ErrDeadlineExceeded}:           ExitTimeout,
+	} {
+		if code := ExitCode(err); code != expected {
+			t.Fatalf("expected exit code %v for %v, got %v", expected, err, code)
+		}
+	}
+	for status, expected := range map[string]int{
+		StatusCompleted: ExitOK, StatusExecuting: ExitOK, StatusFailed: ExitFailed, StatusRejected: ExitRejected,
+		StatusCancelled: ExitCancelled, StatusTimedOut: ExitTimedOut, StatusRequested: ExitAwaitingApproval,
+		"": ExitServerError, "PAUSED": ExitServerError,
+	} {
+		if code := StatusExitCode(status); code != expected {
+			t.Fatalf("expected exit code %v for status %v, got %v", expected, status, code)
+		}
+	}
+}
+
+func TestApprovals(t *testing.T) {
+	rdc := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/MidVision/ws/deployment/job/list":
+			w.Write([]byte(`<DeploymentJobs><DeploymentJob><id>1</id><status>REQUESTED</status></DeploymentJob>` +
+				`<DeploymentJob><id>2</id><status>EXECUTING</status></DeploymentJob></DeploymentJobs>`))
+		case "/MidVision/ws/deployment/approve/job/1":
+			if r.Method != http.MethodPut || r.URL.Query().Get("comment") != "looks good" {
+				t.Errorf("unexpected request: %v %v", r.Method, r.URL)
+			}
+			w.Write([]byte(jobResponse))
+		default:
+			t.Errorf("unexpected request: %v", r.URL)
+		}
+	})
+	pending, err := rdc.ListPendingApprovals(context.Background())
+	if err != nil || len(pending) != 1 || pending[0].Id != 1 {
+		t.Fatalf("unexpected pending approvals: %+v (%v)", pending, err)
+	}
+	if _, err := rdc.ApproveJob(context.Background(), "1", "looks good"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRescheduleJob(t *testing.T) {
+	at := time.Date(2026, 10, 20, 2, 0, 0, 0, time.FixedZone("CET", 3600))
+	rdc := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/MidVision/ws/deployment/reschedule/job/1234" || r.URL.Query().Get("scheduledDate") != "2026-10-20T02:00:00+01:00" {
+			t.Errorf("unexpected request: %v", r.URL)
+		}
+		w.Write([]byte(jobResponse))
+	})
+	if _, err := rdc.RescheduleJob(context.Background(), "1234", at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	redactor := NewRedactor("DB_*", "# not a key")
+	for key, sensitive := range map[string]bool{
+		"@@DB_PASSWORD@@": true, "@@api_token@@": true, "@@DB_URL@@": true, "DB_HOST": true, "@@PORT@@": false,
+	} {
+		if redactor.IsSensitiveKey(key) != sensitive {
+			t.Fatalf("expected %v for key %s", sensitive, key)
+		}
+	}
+
+	var debug strings.Builder
+	rdc := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/user/create/token") {
+			w.Write([]byte("new-token-456"))
+			return
+		}
+		w.Write([]byte(`<html><body><div/><div><div><ul><li><span>Job ID</span><span>1</span></li></ul></div></div></body></html>`))
+	})
+	rdc.Logger = slog.New(slog.NewTextHandler(&debug, &slog.HandlerOptions{Level: LevelTrace}))
+	rdc.Redactor = redactor
+	if err := rdc.CreateToken(context.Background(), "admin", "pa ss=w&rd"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rdc.Deploy(context.Background(), "app", "web.PROD.cfg", "", []string{"@@DB_PASSWORD@@=s3cr&t=1", "@@PORT@@=8080"}, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	output := debug.String()
+	for _, secret := range []string{"pa ss=w&rd", "pa+ss%3Dw%26rd", `pa ss=w\u0026rd`, "new-token-456", "s3cr&t=1", "s3cr%26t%3D1"} {
+		if strings.Contains(output, secret) {
+			t.Fatalf("secret %q not masked:\n%s", secret, output)
+		}
+	}
+	if !strings.Contains(output, "%40%40PORT%40%40%3D8080") || !strings.Contains(output, Mask) {
+		t.Fatalf("expected only the secrets to be masked:\n%s", output)
+	}
+
+	// Even the short secrets are masked
+	redactor.AddSecret("pw")
+	if masked := redactor.Redact("user:pw"); masked != "user:"+Mask {
+		t.Fatalf("short secret not masked: %q", masked)
+	}
+	redactor.AddSecret("")
+	if masked := redactor.Redact("user"); masked != "user" {
+		t.Fatalf("unexpected masking of an empty secret: %q", masked)
+	}
+
+	// Without a redactor nothing is masked
+	var nilRedactor *Redactor
+	nilRedactor.AddSecret("secret")
+	if nilRedactor.Redact("secret") != "secret" {
+		t.Fatal("expected a nil redactor to mask nothing")
+	}
+}
+
+func TestLogCalls(t *testing.T) {
+	var requestIds []string
+	rdc := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requestIds = append(requestIds, r.Header.Get("X-Request-ID"))
+		if len(requestIds) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`<Projects/>`))
+	})
+	var logs strings.Builder
+	rdc.Logger = slog.New(slog.NewJSONHandler(&logs, nil))
+	rdc.RetryMaxWait = time.Millisecond
+	if _, err := rdc.ListProjects(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The retry shares the request ID, and the headers and bodies are not logged at info level
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(requestIds) != 2 || requestIds[0] == "" || requestIds[0] != requestIds[1] || len(lines) != 2 {
+		t.Fatalf("unexpected requests %v or logs:\n%s", requestIds, logs.String())
+	}
+	var call, retry map[string]any
+	json.Unmarshal([]byte(lines[0]), &call)
+	json.Unmarshal([]byte(lines[1]), &retry)
+	if call["level"] != "WARN" || call["request_id"] != requestIds[0] || call["method"] != "GET" || call["status"] != 503.0 ||
+		!strings.HasSuffix(call["url"].(string), "/ws/project/list") || call["latency"] == nil {
+		t.Fatalf("unexpected call entry: %v", call)
+	}
+	if retry["msg"] != "Retrying call" || retry["request_id"] != requestIds[0] {
+		t.Fatalf("unexpected retry entry: %v", retry)
+	}
+}
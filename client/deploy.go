@@ -0,0 +1,78 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DictionaryKey returns a data dictionary key in the @@KEY@@ form of the
+// dictionary tokens, adding the '@@' if the key omits them.
+func DictionaryKey(key string) string {
+	key = strings.TrimSpace(key)
+	if len(key) < 4 || !strings.HasPrefix(key, "@@") || !strings.HasSuffix(key, "@@") {
+		key = "@@" + key + "@@"
+	}
+	return key
+}
+
+// Deploy deploys a project's deployment package to a target, in the form
+// SERVER.INSTALLATION.CONFIGURATION. An empty package name deploys the latest
+// version. Each dictionary item must follow the @@KEY@@=VALUE syntax. A non-zero
+// 'at' time schedules the deployment instead of starting it straight away.
+func (rdc *RDClient) Deploy(ctx context.Context, projectName, targetName, packageName string, dictionaryItems []string, at time.Time) (JobDetails, error) {
+	targetStrip := strings.Split(targetName, ".")
+	if len(targetStrip) != 3 {
+		return nil, fmt.Errorf("Invalid target name '%s'\n"+
+			"The target name has to include the server, the installation and the configuration names:\n"+
+			"e.g. SERVER.INSTALLATION.CONFIGURATION", targetName)
+	}
+	serverName := targetStrip[0]
+	installName := targetStrip[1]
+	configName := targetStrip[2]
+	rdc.Redactor.AddDictionaryItems(dictionaryItems)
+
+	var urlBuffer bytes.Buffer
+	urlBuffer.WriteString("deployment/" + projectName + "/runjob/deploy/" + serverName + "/" + installName + "/" + configName +
+		"?packageName=" + packageName)
+	for _, dictionaryItem := range dictionaryItems {
+		urlBuffer.WriteString("&dictionaryItem=" + url.QueryEscape(dictionaryItem))
+	}
+	if !at.IsZero() {
+		urlBuffer.WriteString("&" + scheduleQuery(at))
+	}
+
+	resData, _, err := rdc.call(ctx, http.MethodPut, urlBuffer.String(), nil, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+	return rdc.jobDetails(resData)
+}
+
+// DefaultTarget returns the first target of a project whose server has a
+// 'localhost' hostname, or an empty string if there is none.
+func (rdc *RDClient) DefaultTarget(ctx context.Context, projectName string) (string, error) {
+	targets, err := rdc.ListTargets(ctx, projectName)
+	if err != nil {
+		return "", err
+	}
+	for _, targetName := range targets {
+		rdc.log(ctx, slog.LevelDebug, "Checking the hostnames of a target", "target", targetName)
+		server, err := rdc.GetServer(ctx, strings.Split(targetName, ".")[0])
+		if err != nil {
+			return "", err
+		}
+		rdc.log(ctx, slog.LevelDebug, "Hostnames found", "target", targetName, "hostnames", fmt.Sprint(server.Hostnames))
+		if strings.Contains(server.Hostname, "localhost") {
+			return targetName, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,101 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type (
+	// ResponseError is returned when the server answers a call with an
+	// unexpected status code. Messages holds the texts found in the
+	// HTML error page returned by the server, if any.
+	//
+	// The more specific NotFoundError, UnauthorizedError, ValidationError
+	// and ServerError types all wrap a ResponseError.
+	ResponseError struct {
+		Url        string
+		StatusCode int
+		Messages   []string
+	}
+
+	// NotFoundError is returned when the requested entity, e.g. a project,
+	// a server or a target, does not exist in RapidDeploy.
+	NotFoundError struct {
+		*ResponseError
+	}
+
+	// UnauthorizedError is returned when the authentication token is not
+	// valid or the user is not allowed to perform the call.
+	UnauthorizedError struct {
+		*ResponseError
+	}
+
+	// ValidationError is returned when the server rejects the request,
+	// e.g. because an argument is not valid. Fields holds each message of
+	// the error page along with the title it was reported under.
+	ValidationError struct {
+		*ResponseError
+		Fields []*FieldError
+	}
+
+	// ServerError is returned when the server fails to process a call.
+	ServerError struct {
+		*ResponseError
+	}
+
+	// A single message of a ValidationError.
+	FieldError struct {
+		Field   string
+		Message string
+	}
+)
+
+func (e *ResponseError) Error() string {
+	msg := fmt.Sprintf("Server returned response code %v: %v", e.StatusCode, http.StatusText(e.StatusCode))
+	if len(e.Messages) != 0 {
+		msg += "\n" + strings.Join(e.Messages, "\n")
+	}
+	return msg
+}
+
+func (e *NotFoundError) Unwrap() error     { return e.ResponseError }
+func (e *UnauthorizedError) Unwrap() error { return e.ResponseError }
+func (e *ValidationError) Unwrap() error   { return e.ResponseError }
+func (e *ServerError) Unwrap() error       { return e.ResponseError }
+
+// Decodes the response of a call into one of the error types above, or
+// returns nil for a successful response. Responses whose body does not have
+// the expected shape are classified by their status code only.
+func decodeResponse(rdUrl string, statusCode int, resData []byte) error {
+	if statusCode == http.StatusOK {
+		return nil
+	}
+
+	// An unexpected body just means there are no messages to report
+	messages, _ := responseMessages(resData)
+	resErr := &ResponseError{Url: rdUrl, StatusCode: statusCode}
+	var fields []*FieldError
+	notFound := statusCode == http.StatusNotFound
+	for _, message := range messages {
+		resErr.Messages = append(resErr.Messages, message.Value())
+		fields = append(fields, &FieldError{Field: message.Title(), Message: message.Value()})
+		if strings.Contains(message.Value(), "No entity found") {
+			notFound = true
+		}
+	}
+
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return &UnauthorizedError{resErr}
+	case notFound:
+		return &NotFoundError{resErr}
+	case statusCode == http.StatusBadRequest:
+		return &ValidationError{resErr, fields}
+	case statusCode >= 500:
+		return &ServerError{resErr}
+	}
+	return resErr
+}
@@ -0,0 +1,106 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/url"
+)
+
+// Exit codes of the rd command line tool, so scripts can tell the outcome
+// of a command apart: errors running the command are below 10 and the
+// outcomes of a job which did not finish successfully are from 10 on.
+const (
+	ExitOK              = 0
+	ExitError           = 1
+	ExitUsage           = 2
+	ExitUnauthorized    = 3
+	ExitNotFound        = 4
+	ExitServerError     = 5
+	ExitConnectionError = 6
+	ExitInterrupted     = 7
+	ExitTimeout         = 8
+
+	ExitFailed           = 10
+	ExitRejected         = 11
+	ExitCancelled        = 12
+	ExitTimedOut         = 13
+	ExitAwaitingApproval = 14
+)
+
+// ExitCode returns the exit code for an error returned by the client. An
+// interrupted command or a request to the server which timed out is an error
+// running the command, not the outcome of a job: ExitCancelled and
+// ExitTimedOut are only returned for the status of a job.
+func ExitCode(err error) int {
+	var notFoundErr *NotFoundError
+	var unauthorizedErr *UnauthorizedError
+	var serverErr *ServerError
+	var urlErr *url.Error
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &urlErr) && urlErr.Timeout():
+		return ExitTimeout
+	case errors.As(err, &unauthorizedErr):
+		return ExitUnauthorized
+	case errors.As(err, &notFoundErr):
+		return ExitNotFound
+	case errors.As(err, &serverErr):
+		return ExitServerError
+	case errors.As(err, &urlErr):
+		return ExitConnectionError
+	}
+	return ExitError
+}
+
+// StatusExitCode returns the exit code for the status of a job. A job which
+// is running or scheduled has no outcome yet and returns ExitOK. A missing or
+// unknown status returns ExitServerError, as the outcome of the job is unknown.
+func StatusExitCode(status string) int {
+	switch status {
+	case StatusCompleted, StatusScheduled, StatusQueued, StatusStarting, StatusDeploying, StatusExecuting:
+		return ExitOK
+	case StatusRequested, StatusRequestedScheduled:
+		return ExitAwaitingApproval
+	case StatusRejected:
+		return ExitRejected
+	case StatusCancelled:
+		return ExitCancelled
+	case StatusTimedOut:
+		return ExitTimedOut
+	case StatusFailed, StatusUnexecutable, StatusUnknown:
+		return ExitFailed
+	}
+	return ExitServerError
+}
+
+// Exit codes from the least to the most severe outcome, to report the worst
+// one of several operations. An error running an operation is worse than any
+// outcome of a job, as the state of the job is unknown.
+var exitCodeSeverity = []int{
+	ExitOK, ExitAwaitingApproval, ExitCancelled, ExitRejected, ExitTimedOut, ExitFailed,
+	ExitInterrupted, ExitTimeout, ExitNotFound, ExitUnauthorized, ExitServerError, ExitConnectionError,
+	ExitError, ExitUsage,
+}
+
+// WorstExitCode returns the most severe of the given exit codes, or ExitOK if there are none.
+func WorstExitCode(exitCodes ...int) int {
+	worst, worstSeverity := ExitOK, 0
+	for _, exitCode := range exitCodes {
+		severity := len(exitCodeSeverity)
+		for i, code := range exitCodeSeverity {
+			if code == exitCode {
+				severity = i
+				break
+			}
+		}
+		if severity > worstSeverity {
+			worst, worstSeverity = exitCode, severity
+		}
+	}
+	return worst
+}
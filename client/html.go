@@ -0,0 +1,132 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package client
+
+import (
+	"encoding/xml"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// Declared here as it is used in different entity structs.
+	PluginDataSet struct {
+		Id         int16  `xml:"id,omitempty" json:"id,omitempty"`
+		PluginData string `xml:"pluginData,omitempty" json:"pluginData,omitempty"`
+	}
+
+	//**********************************************
+	// Generic structure of a web service response.
+	Html struct {
+		Head *Head `xml:"head,omitempty"`
+		Body *Body `xml:"body,omitempty"`
+	}
+
+	Head struct {
+		Title string `xml:"title,omitempty"`
+	}
+
+	Body struct {
+		Div []*Div `xml:"div,omitempty"`
+	}
+
+	Div struct {
+		H2  string `xml:"h2,omitempty"`
+		Div []*Div `xml:"div,omitempty"`
+		Ul  *Ul    `xml:"ul,omitempty"`
+	}
+
+	Ul struct {
+		Li []*Li `xml:"li,omitempty"`
+	}
+
+	Li struct {
+		Span []string `xml:"span,omitempty"`
+	}
+	//**********************************************
+
+	// JobDetails holds the title/value messages returned by the
+	// server when a job is started or its details are requested.
+	JobDetails []*Li
+
+	// JobSummary is the serializable form of the JobDetails of a job.
+	JobSummary struct {
+		JobId   string       `json:"jobId"`
+		Status  string       `json:"status,omitempty"`
+		Details []*JobDetail `json:"details"`
+	}
+
+	JobDetail struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+)
+
+// Title returns the first span of the message, or an empty string.
+func (li *Li) Title() string {
+	if len(li.Span) < 1 {
+		return ""
+	}
+	return li.Span[0]
+}
+
+// Value returns the second span of the message, or an empty string.
+func (li *Li) Value() string {
+	if len(li.Span) < 2 {
+		return ""
+	}
+	return li.Span[1]
+}
+
+// Returns the messages contained in a web service response.
+// An empty slice is returned if the response does not have the expected shape.
+func responseMessages(htmlContent []byte) ([]*Li, error) {
+	htmlObject := new(Html)
+	if err := xml.Unmarshal(htmlContent, &htmlObject); err != nil {
+		return nil, err
+	}
+	if htmlObject.Body == nil ||
+		len(htmlObject.Body.Div) < 2 ||
+		len(htmlObject.Body.Div[1].Div) < 1 ||
+		htmlObject.Body.Div[1].Div[0].Ul == nil {
+		return []*Li{}, nil
+	}
+	return htmlObject.Body.Div[1].Div[0].Ul.Li, nil
+}
+
+// Get returns the value of the first message whose title contains 'title'.
+func (d JobDetails) Get(title string) string {
+	for _, message := range d {
+		if strings.Contains(message.Title(), title) {
+			return message.Value()
+		}
+	}
+	return ""
+}
+
+// Id returns the ID of the job.
+func (d JobDetails) Id() string {
+	return d.Get("Job ID")
+}
+
+// Status returns the status of the job, e.g. EXECUTING or COMPLETED.
+func (d JobDetails) Status() string {
+	return d.Get("Job Status")
+}
+
+// Summary returns the serializable form of the job details.
+func (d JobDetails) Summary() *JobSummary {
+	summary := &JobSummary{JobId: d.Id(), Status: d.Status(), Details: []*JobDetail{}}
+	for _, message := range d {
+		summary.Details = append(summary.Details, &JobDetail{Name: message.Title(), Value: message.Value()})
+	}
+	return summary
+}
+
+// LogFilename returns the name of the log file of the job.
+func (d JobDetails) LogFilename() string {
+	if logPath := d.Get("File Path"); logPath != "" {
+		return filepath.Base(logPath)
+	}
+	return ""
+}
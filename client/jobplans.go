@@ -0,0 +1,116 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package client
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	JobPlans struct {
+		JobPlan []*JobPlan `xml:"JobPlan,omitempty" json:"jobPlan,omitempty"`
+	}
+
+	JobPlan struct {
+		Description  string `xml:"description,omitempty" json:"description,omitempty"`
+		Id           int    `xml:"id,omitempty" json:"id,omitempty"`
+		Name         string `xml:"name,omitempty" json:"name,omitempty"`
+		PlanData     string `xml:"planData,omitempty" json:"planData,omitempty"`
+		SecurityName string `xml:"securityName,omitempty" json:"securityName,omitempty"`
+	}
+
+	// JobPlanRun is the state of a started job plan and of the jobs it spawned.
+	JobPlanRun struct {
+		*JobSummary
+		Jobs []*Job `json:"jobs"`
+	}
+)
+
+// ListJobPlans returns the job plans available in RapidDeploy.
+func (rdc *RDClient) ListJobPlans(ctx context.Context) ([]*JobPlan, error) {
+	resData, _, err := rdc.call(ctx, http.MethodGet, "deployment/jobPlan/list", nil, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+	rdJobPlans := new(JobPlans)
+	if err := xml.Unmarshal(resData, &rdJobPlans); err != nil {
+		return nil, err
+	}
+	return rdJobPlans.JobPlan, nil
+}
+
+// RunJobPlan starts a job plan and returns the details of the started job.
+// A non-zero 'at' time schedules the job plan instead of starting it straight away.
+func (rdc *RDClient) RunJobPlan(ctx context.Context, jobPlanId int, at time.Time) (JobDetails, error) {
+	relUrl := "deployment/jobPlan/run/" + strconv.Itoa(jobPlanId)
+	if !at.IsZero() {
+		relUrl += "?" + scheduleQuery(at)
+	}
+	resData, _, err := rdc.call(ctx, http.MethodPut, relUrl, nil, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+	details, err := rdc.jobDetails(resData)
+	if err != nil {
+		return nil, err
+	}
+	for _, message := range details {
+		if len(message.Span) > 1 {
+			message.Span[1] = strings.Replace(message.Span[1], "com.midvision.rapiddeploy.domain.jobplan.", "", -1)
+		}
+	}
+	return details, nil
+}
+
+// GetJobPlanRun returns the state of a started job plan, given the job ID
+// returned by RunJobPlan, and of the jobs spawned by its steps.
+func (rdc *RDClient) GetJobPlanRun(ctx context.Context, runId string) (*JobPlanRun, error) {
+	details, err := rdc.GetJob(ctx, runId)
+	if err != nil {
+		return nil, err
+	}
+	resData, _, err := rdc.call(ctx, http.MethodGet, "deployment/jobPlan/run/"+runId+"/jobs", nil, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+	rdJobs := new(Jobs)
+	if err := xml.Unmarshal(resData, &rdJobs); err != nil {
+		return nil, err
+	}
+	run := &JobPlanRun{JobSummary: details.Summary(), Jobs: rdJobs.Job}
+	if run.Jobs == nil {
+		run.Jobs = []*Job{}
+	}
+	return run, nil
+}
+
+// Finished reports whether the job plan and all of its jobs have finished.
+func (run *JobPlanRun) Finished() bool {
+	if !IsFinished(run.Status) {
+		return false
+	}
+	for _, job := range run.Jobs {
+		if !IsFinished(job.Status) {
+			return false
+		}
+	}
+	return true
+}
+
+// Successful reports whether the job plan and all of its jobs have finished successfully.
+func (run *JobPlanRun) Successful() bool {
+	if !IsSuccessful(run.Status) {
+		return false
+	}
+	for _, job := range run.Jobs {
+		if !IsSuccessful(job.Status) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,148 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package client
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Statuses of a job reported by RapidDeploy.
+const (
+	StatusRequested          = "REQUESTED"
+	StatusRequestedScheduled = "REQUESTED_SCHEDULED"
+	StatusScheduled          = "SCHEDULED"
+	StatusQueued             = "QUEUED"
+	StatusStarting           = "STARTING"
+	StatusDeploying          = "DEPLOYING"
+	StatusExecuting          = "EXECUTING"
+	StatusCompleted          = "COMPLETED"
+	StatusFailed             = "FAILED"
+	StatusRejected           = "REJECTED"
+	StatusCancelled          = "CANCELLED"
+	StatusUnexecutable       = "UNEXECUTABLE"
+	StatusTimedOut           = "TIMEDOUT"
+	StatusUnknown            = "UNKNOWN"
+)
+
+type (
+	Jobs struct {
+		Job []*Job `xml:"DeploymentJob,omitempty" json:"job,omitempty"`
+	}
+
+	// Job is the summary of a job returned when listing the jobs.
+	Job struct {
+		Id int `xml:"id,omitempty" json:"id"`
+		// The step of the job plan which spawned the job, if any
+		Step        string `xml:"step,omitempty" json:"step,omitempty"`
+		ProjectName string `xml:"projectName,omitempty" json:"projectName,omitempty"`
+		Target      string `xml:"target,omitempty" json:"target,omitempty"`
+		PackageName string `xml:"packageName,omitempty" json:"packageName,omitempty"`
+		Status      string `xml:"status,omitempty" json:"status,omitempty"`
+		RequestedBy string `xml:"requestedBy,omitempty" json:"requestedBy,omitempty"`
+		StartDate   string `xml:"startDate,omitempty" json:"startDate,omitempty"`
+		EndDate     string `xml:"endDate,omitempty" json:"endDate,omitempty"`
+		// The date a scheduled job starts at
+		ScheduledDate string `xml:"scheduledDate,omitempty" json:"scheduledDate,omitempty"`
+	}
+)
+
+// IsRunning reports whether a job with the given status is queued or executing.
+func IsRunning(status string) bool {
+	switch status {
+	case StatusQueued, StatusStarting, StatusDeploying, StatusExecuting:
+		return true
+	}
+	return false
+}
+
+// IsPending reports whether a job with the given status is waiting for an
+// approval or for its scheduled date to start.
+func IsPending(status string) bool {
+	switch status {
+	case StatusRequested, StatusRequestedScheduled, StatusScheduled:
+		return true
+	}
+	return false
+}
+
+// IsFinished reports whether a job with the given status has finished.
+func IsFinished(status string) bool {
+	return !IsRunning(status) && !IsPending(status)
+}
+
+// IsSuccessful reports whether a job with the given status has finished successfully.
+func IsSuccessful(status string) bool {
+	return status == StatusCompleted
+}
+
+// GetJob returns the details of a deployment job.
+func (rdc *RDClient) GetJob(ctx context.Context, jobId string) (JobDetails, error) {
+	resData, _, err := rdc.call(ctx, http.MethodGet, "deployment/display/job/"+jobId, nil, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+	return rdc.jobDetails(resData)
+}
+
+// GetJobLog returns the content of the log file of a deployment job.
+func (rdc *RDClient) GetJobLog(ctx context.Context, jobId string) ([]byte, error) {
+	resData, _, err := rdc.transfer(ctx, http.MethodGet, "deployment/showlog/job/"+jobId, nil, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+	return resData, nil
+}
+
+// CancelJob cancels a job which has not finished and returns its details.
+func (rdc *RDClient) CancelJob(ctx context.Context, jobId string) (JobDetails, error) {
+	resData, _, err := rdc.call(ctx, http.MethodPut, "deployment/cancel/job/"+jobId, nil, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+	return rdc.jobDetails(resData)
+}
+
+// ListJobs returns the jobs of a project, or of every project if the
+// project name is empty, most recent first.
+func (rdc *RDClient) ListJobs(ctx context.Context, projectName string) ([]*Job, error) {
+	relUrl := "deployment/job/list"
+	if projectName != "" {
+		relUrl += "?projectName=" + url.QueryEscape(projectName)
+	}
+	resData, _, err := rdc.call(ctx, http.MethodGet, relUrl, nil, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+	rdJobs := new(Jobs)
+	if err := xml.Unmarshal(resData, &rdJobs); err != nil {
+		return nil, err
+	}
+	return rdJobs.Job, nil
+}
+
+// RescheduleJob changes the date a scheduled job starts at and returns its details.
+func (rdc *RDClient) RescheduleJob(ctx context.Context, jobId string, at time.Time) (JobDetails, error) {
+	resData, _, err := rdc.call(ctx, http.MethodPut, "deployment/reschedule/job/"+jobId+"?"+scheduleQuery(at), nil, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+	return rdc.jobDetails(resData)
+}
+
+// Returns the query parameter to schedule a job at the given time. The time is
+// sent with its offset, so the server schedules it in the time zone of the user.
+func scheduleQuery(at time.Time) string {
+	return "scheduledDate=" + url.QueryEscape(at.Format(time.RFC3339))
+}
+
+func (rdc *RDClient) jobDetails(resData []byte) (JobDetails, error) {
+	messages, err := responseMessages(resData)
+	if err != nil {
+		return nil, err
+	}
+	return JobDetails(messages), nil
+}
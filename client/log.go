@@ -0,0 +1,59 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// LevelTrace is the log level of the headers and bodies of the calls, below slog.LevelDebug.
+const LevelTrace = slog.LevelDebug - 4
+
+// Header sending the ID of each call, to find it in the logs of the server.
+const requestIdHeader = "X-Request-ID"
+
+// Returns a random ID for a call, shared by its retries.
+func newRequestId() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Logs an entry with its string arguments redacted, if the client has a logger.
+func (rdc *RDClient) log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if rdc.Logger == nil || !rdc.Logger.Enabled(ctx, level) {
+		return
+	}
+	for i, arg := range args {
+		switch value := arg.(type) {
+		case string:
+			args[i] = rdc.Redactor.Redact(value)
+		case error:
+			args[i] = rdc.Redactor.Redact(value.Error())
+		}
+	}
+	rdc.Logger.Log(ctx, level, msg, args...)
+}
+
+// Logs an attempt of a call: at debug level if the server answered, or at
+// warning level if it failed or could not be reached.
+func (rdc *RDClient) logCall(ctx context.Context, requestId, method, reqUrl string, attempt, statusCode int, latency time.Duration, err error) {
+	args := []any{"request_id", requestId, "method", method, "url", reqUrl}
+	if attempt > 0 {
+		args = append(args, "retry", attempt)
+	}
+	args = append(args, "latency", latency)
+	switch {
+	case err != nil:
+		rdc.log(ctx, slog.LevelWarn, "HTTP request failed", append(args, "error", err)...)
+	case statusCode >= http.StatusInternalServerError:
+		rdc.log(ctx, slog.LevelWarn, "HTTP request", append(args, "status", statusCode)...)
+	default:
+		rdc.log(ctx, slog.LevelDebug, "HTTP request", append(args, "status", statusCode)...)
+	}
+}
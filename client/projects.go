@@ -0,0 +1,101 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package client
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+)
+
+type (
+	Projects struct {
+		Project []*Project `xml:"Project,omitempty" json:"project,omitempty"`
+	}
+
+	Project struct {
+		CreateDate            string         `xml:"createDate,omitempty" json:"createDate,omitempty"`
+		Description           string         `xml:"description,omitempty" json:"description,omitempty"`
+		Enabled               bool           `xml:"enabled,omitempty" json:"enabled"`
+		LogDirectory          string         `xml:"logDirectory,omitempty" json:"logDirectory,omitempty"`
+		Name                  string         `xml:"name,omitempty" json:"name,omitempty"`
+		Optlock               int            `xml:"optlock,omitempty" json:"optlock,omitempty"`
+		OrchestrationFileName string         `xml:"orchestrationFileName,omitempty" json:"orchestrationFileName,omitempty"`
+		Owner                 *Owner         `xml:"owner,omitempty" json:"owner,omitempty"`
+		PluginDataSet         *PluginDataSet `xml:"pluginDataSet,omitempty" json:"pluginDataSet,omitempty"`
+	}
+
+	Owner struct {
+		Description string `xml:"description,omitempty" json:"description,omitempty"`
+		Email       string `xml:"email,omitempty" json:"email,omitempty"`
+		Enabled     bool   `xml:"enabled,omitempty" json:"enabled"`
+		Firstname   string `xml:"firstname,omitempty" json:"firstname,omitempty"`
+		Lastname    string `xml:"lastname,omitempty" json:"lastname,omitempty"`
+		Optlock     int    `xml:"optlock,omitempty" json:"optlock,omitempty"`
+		SourceType  bool   `xml:"sourceType,omitempty" json:"sourceType"`
+		Username    string `xml:"username,omitempty" json:"username,omitempty"`
+	}
+)
+
+// ListProjects returns the projects available in RapidDeploy.
+func (rdc *RDClient) ListProjects(ctx context.Context) ([]*Project, error) {
+	resData, _, err := rdc.call(ctx, http.MethodGet, "project/list", nil, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+	rdProjects := new(Projects)
+	if err := xml.Unmarshal(resData, &rdProjects); err != nil {
+		return nil, err
+	}
+	return rdProjects.Project, nil
+}
+
+// ListTargets returns the names of the targets available for a project,
+// in the form SERVER.INSTALLATION.CONFIGURATION.
+func (rdc *RDClient) ListTargets(ctx context.Context, projectName string) ([]string, error) {
+	resData, _, err := rdc.call(ctx, http.MethodGet, "project/"+projectName+"/list", nil, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+	messages, err := responseMessages(resData)
+	if err != nil {
+		return nil, err
+	}
+	var targets []string
+	for _, target := range messages {
+		targets = append(targets, target.Value())
+	}
+	return targets, nil
+}
+
+// ListPackages returns the names of the deployment packages built for a project.
+func (rdc *RDClient) ListPackages(ctx context.Context, projectName string) ([]string, error) {
+	resData, _, err := rdc.call(ctx, http.MethodGet, "deployment/"+projectName+"/package/list", nil, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+	messages, err := responseMessages(resData)
+	if err != nil {
+		return nil, err
+	}
+	var packages []string
+	for _, pkg := range messages {
+		packages = append(packages, pkg.Value())
+	}
+	return packages, nil
+}
+
+// Export returns the content of the ZIP archive of a project.
+func (rdc *RDClient) Export(ctx context.Context, projectName string) ([]byte, error) {
+	resData, _, err := rdc.transfer(ctx, http.MethodGet, "project/"+projectName+"/export", nil, "application/zip")
+	if err != nil {
+		return nil, err
+	}
+	return resData, nil
+}
+
+// Import imports the content of a project ZIP archive into RapidDeploy.
+func (rdc *RDClient) Import(ctx context.Context, projectArchive []byte) error {
+	_, _, err := rdc.transfer(ctx, http.MethodPut, "project/import", projectArchive, "application/zip")
+	return err
+}
@@ -0,0 +1,98 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package client
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The text replacing the secrets.
+const Mask = "****"
+
+// Data dictionary keys whose values are always secret.
+var DefaultSensitiveKeys = []string{
+	"@@*PASSWORD*@@", "@@*PASSWD*@@", "@@*SECRET*@@", "@@*TOKEN*@@",
+	"@@*PRIVATE_KEY*@@", "@@*API_KEY*@@", "@@*CREDENTIAL*@@",
+}
+
+// Redactor masks secrets in the text shown to the user: the authentication
+// token, the passwords and the values of the sensitive data dictionary keys.
+// The secrets are registered as the client uses them, and masked as they are
+// and escaped for URLs and JSON. A nil Redactor masks nothing.
+type Redactor struct {
+	mu            sync.Mutex
+	sensitiveKeys []string
+	secrets       []string
+}
+
+// NewRedactor returns a Redactor for which the data dictionary keys matching
+// DefaultSensitiveKeys or the given patterns, e.g. '@@DB_*@@' or 'DB_*', are sensitive.
+func NewRedactor(sensitiveKeys ...string) *Redactor {
+	r := &Redactor{}
+	for _, key := range append(DefaultSensitiveKeys, sensitiveKeys...) {
+		r.sensitiveKeys = append(r.sensitiveKeys, strings.ToUpper(DictionaryKey(key)))
+	}
+	return r
+}
+
+// IsSensitiveKey returns whether the values of a data dictionary key are secret.
+func (r *Redactor) IsSensitiveKey(key string) bool {
+	if r == nil {
+		return false
+	}
+	key = strings.ToUpper(DictionaryKey(key))
+	for _, pattern := range r.sensitiveKeys {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// AddSecret registers a secret to mask.
+func (r *Redactor) AddSecret(secret string) {
+	if r == nil || secret == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// As it is, in the URLs and in the JSON request bodies
+	jsonSecret, _ := json.Marshal(secret)
+	forms := []string{secret, url.QueryEscape(secret), url.PathEscape(secret), strings.Trim(string(jsonSecret), `"`)}
+	for _, form := range forms {
+		if !slices.Contains(r.secrets, form) {
+			r.secrets = append(r.secrets, form)
+		}
+	}
+	// The longest secrets first, so one containing another is masked whole
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+}
+
+// AddDictionaryItems registers the values of the sensitive items of a data
+// dictionary, given as @@KEY@@=value.
+func (r *Redactor) AddDictionaryItems(items []string) {
+	for _, item := range items {
+		if key, value, ok := strings.Cut(item, "="); ok && r.IsSensitiveKey(key) {
+			r.AddSecret(value)
+		}
+	}
+}
+
+// Redact returns a text with its secrets masked.
+func (r *Redactor) Redact(text string) string {
+	if r == nil {
+		return text
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, secret := range r.secrets {
+		text = strings.ReplaceAll(text, secret, Mask)
+	}
+	return text
+}
@@ -0,0 +1,85 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// Default number of times a failed idempotent call is retried.
+	DefaultRetries = 3
+	// Default maximum duration to wait before retrying a call.
+	DefaultRetryMaxWait = 30 * time.Second
+
+	// Duration to wait before the first retry, doubled on every new retry.
+	retryBaseWait = 500 * time.Millisecond
+)
+
+// Checks if a call that failed with the given status code or error can be
+// retried. Only idempotent calls are retried, as a call like starting a
+// deployment may have reached the server even if its response did not.
+func retryable(ctx context.Context, method string, statusCode int, err error) bool {
+	if method != http.MethodGet || ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return temporary(err)
+	}
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Checks if a transport error is a timeout or a temporary network failure.
+// Retrying does not fix a refused connection nor a server that cannot be
+// trusted, so TLS and certificate errors are not temporary.
+func temporary(err error) bool {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr):
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNABORTED), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		// The connection was closed by the server or a proxy
+		return true
+	case errors.As(err, &netErr):
+		return netErr.Timeout()
+	}
+	return false
+}
+
+// Returns the duration to wait before a retry: the one requested by the server
+// in the 'Retry-After' header if any, otherwise an exponential backoff with jitter.
+func retryWait(attempt int, header http.Header, maxWait time.Duration) time.Duration {
+	wait := time.Duration(-1)
+	if retryAfter := header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			wait = time.Duration(seconds) * time.Second
+		} else if date, err := http.ParseTime(retryAfter); err == nil {
+			wait = time.Until(date)
+		}
+	}
+	if wait < 0 {
+		backoff := retryBaseWait << attempt
+		if backoff <= 0 || backoff > maxWait {
+			backoff = maxWait
+		}
+		// Wait a random duration between half and the whole backoff
+		wait = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	}
+	if wait > maxWait {
+		wait = maxWait
+	}
+	return wait
+}
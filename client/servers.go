@@ -0,0 +1,98 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package client
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+)
+
+type (
+	// Struct type that will hold the XML response from the REST call
+	Servers struct {
+		Server []*Server `xml:"Server,omitempty" json:"server,omitempty"`
+	}
+
+	Server struct {
+		BuildStore            string                   `xml:"buildStore,omitempty" json:"buildStore,omitempty"`
+		Displayname           string                   `xml:"displayname,omitempty" json:"displayname,omitempty"`
+		EnvironmentProperties []*EnvironmentProperties `xml:"environmentProperties,omitempty" json:"environmentProperties,omitempty"`
+		Hostname              string                   `xml:"hostname,omitempty" json:"hostname,omitempty"`
+		Hostnames             []string                 `xml:"hostnames,omitempty" json:"hostnames,omitempty"`
+		Optlock               int                      `xml:"optlock,omitempty" json:"optlock,omitempty"`
+		PluginDataSet         *PluginDataSet           `xml:"pluginDataSet,omitempty" json:"pluginDataSet,omitempty"`
+		Product               string                   `xml:"product,omitempty" json:"product,omitempty"`
+		ServerEnabled         bool                     `xml:"serverEnabled,omitempty" json:"serverEnabled"`
+		Version               string                   `xml:"version,omitempty" json:"version,omitempty"`
+	}
+
+	EnvironmentProperties struct {
+		Id    int    `xml:"id,omitempty" json:"id,omitempty"`
+		Key   string `xml:"key,omitempty" json:"key,omitempty"`
+		Value string `xml:"value,omitempty" json:"value,omitempty"`
+	}
+
+	Environments struct {
+		Environment []*Environment `xml:"environment,omitempty" json:"environment,omitempty"`
+	}
+
+	Environment struct {
+		EnvType            *EnvType `xml:"envType,omitempty" json:"envType,omitempty"`
+		EnvTypeName        string   `xml:"envTypeName,omitempty" json:"envTypeName,omitempty"`
+		EnvironmentEnabled bool     `xml:"environmentEnabled,omitempty" json:"environmentEnabled"`
+		Hostname           string   `xml:"hostname,omitempty" json:"hostname,omitempty"`
+		Id                 int      `xml:"id,omitempty" json:"id,omitempty"`
+		Name               string   `xml:"name,omitempty" json:"name,omitempty"`
+		Optlock            int      `xml:"optlock,omitempty" json:"optlock,omitempty"`
+		Owner              string   `xml:"owner,omitempty" json:"owner,omitempty"`
+		ServerDisplayName  string   `xml:"serverDisplayName,omitempty" json:"serverDisplayName,omitempty"`
+		SnapshotsPath      string   `xml:"snapshotsPath,omitempty" json:"snapshotsPath,omitempty"`
+		Validated          bool     `xml:"validated,omitempty" json:"validated"`
+	}
+
+	EnvType struct {
+		Live                       string `xml:"live,attr" json:"live,omitempty"`
+		Name                       string `xml:"name,attr" json:"name,omitempty"`
+		ConfigurationApprovalGroup string `xml:"configurationApprovalGroup,omitempty" json:"configurationApprovalGroup,omitempty"`
+	}
+)
+
+// ListServers returns the servers available in RapidDeploy.
+func (rdc *RDClient) ListServers(ctx context.Context) ([]*Server, error) {
+	resData, _, err := rdc.call(ctx, http.MethodGet, "server/list", nil, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+	rdServers := new(Servers)
+	if err := xml.Unmarshal(resData, &rdServers); err != nil {
+		return nil, err
+	}
+	return rdServers.Server, nil
+}
+
+// GetServer returns the details of a server.
+func (rdc *RDClient) GetServer(ctx context.Context, serverName string) (*Server, error) {
+	resData, _, err := rdc.call(ctx, http.MethodGet, "server/"+serverName, nil, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+	server := new(Server)
+	if err := xml.Unmarshal(resData, &server); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// ListInstallations returns the installations (environments) of a server.
+func (rdc *RDClient) ListInstallations(ctx context.Context, serverName string) ([]*Environment, error) {
+	resData, _, err := rdc.call(ctx, http.MethodGet, "environment/"+serverName+"/list", nil, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+	rdEnvironments := new(Environments)
+	if err := xml.Unmarshal(resData, &rdEnvironments); err != nil {
+		return nil, err
+	}
+	return rdEnvironments.Environment, nil
+}
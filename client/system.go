@@ -0,0 +1,26 @@
+// Copyright © 2024 Rafael Ruiz Palacios <support@midvision.com>
+
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// GetSystemInfo returns the general information of the RapidDeploy server.
+func (rdc *RDClient) GetSystemInfo(ctx context.Context) ([]byte, error) {
+	resData, _, err := rdc.call(ctx, http.MethodGet, "system/general-info", nil, "text/xml")
+	return resData, err
+}
+
+// GetConfiguration returns the content of the RapidDeploy properties file.
+func (rdc *RDClient) GetConfiguration(ctx context.Context) ([]byte, error) {
+	resData, _, err := rdc.call(ctx, http.MethodGet, "system/configuration", nil, "text/xml")
+	return resData, err
+}
+
+// GetApplicationLogs returns a ZIP archive with the RapidDeploy server logs.
+func (rdc *RDClient) GetApplicationLogs(ctx context.Context) ([]byte, error) {
+	resData, _, err := rdc.transfer(ctx, http.MethodGet, "system/application-logs", nil, "application/zip")
+	return resData, err
+}
@@ -0,0 +1,102 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSOptions configures the TLS connections with the RapidDeploy server.
+// The certificates and keys are read from the given files when the first
+// call is performed.
+type TLSOptions struct {
+	// PEM file with the certificate authorities trusted in addition to the system ones.
+	CACertFile string `json:"caCert,omitempty"`
+	// PEM files with the certificate and the private key used for mutual TLS.
+	ClientCertFile string `json:"clientCert,omitempty"`
+	ClientKeyFile  string `json:"clientKey,omitempty"`
+	// Disables the verification of the server certificate. Insecure!
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+	// Base64 SHA-256 hashes of the public keys accepted for the server, optionally
+	// prefixed with 'sha256//'. The connection fails if no certificate of the
+	// verified server chains has one of these public keys, or if the server
+	// certificate itself does not have one when the verification is disabled.
+	PinnedPublicKeys []string `json:"pinnedPublicKeys,omitempty"`
+}
+
+// PinError is returned when the public key of the server does not match
+// any of the pinned public keys.
+type PinError struct {
+	ServerName string
+}
+
+func (e *PinError) Error() string {
+	return fmt.Sprintf("The public key of server '%s' does not match any pinned public key", e.ServerName)
+}
+
+// Returns the TLS configuration for the options.
+func (o *TLSOptions) config() (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+
+	if o.CACertFile != "" {
+		caCerts, err := os.ReadFile(o.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the CA certificates file: %v", err)
+		}
+		config.RootCAs, err = x509.SystemCertPool()
+		if err != nil {
+			config.RootCAs = x509.NewCertPool()
+		}
+		if !config.RootCAs.AppendCertsFromPEM(caCerts) {
+			return nil, fmt.Errorf("No valid PEM certificates found in file '%s'", o.CACertFile)
+		}
+	}
+
+	if o.ClientCertFile != "" || o.ClientKeyFile != "" {
+		if o.ClientCertFile == "" || o.ClientKeyFile == "" {
+			return nil, fmt.Errorf("Both the client certificate and the client key files are required for mutual TLS")
+		}
+		clientCert, err := tls.LoadX509KeyPair(o.ClientCertFile, o.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load the client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{clientCert}
+	}
+
+	if len(o.PinnedPublicKeys) != 0 {
+		pins := make(map[string]bool)
+		for _, pin := range o.PinnedPublicKeys {
+			pins[strings.TrimPrefix(pin, "sha256//")] = true
+		}
+		// Also called when the certificate verification is disabled. Only the
+		// verified chains are checked, as the server may send any other certificate.
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			chains := cs.VerifiedChains
+			if o.InsecureSkipVerify && len(cs.PeerCertificates) != 0 {
+				chains = [][]*x509.Certificate{cs.PeerCertificates[:1]}
+			}
+			for _, chain := range chains {
+				for _, cert := range chain {
+					if pins[PublicKeyPin(cert)] {
+						return nil
+					}
+				}
+			}
+			return &PinError{cs.ServerName}
+		}
+	}
+	return config, nil
+}
+
+// PublicKeyPin returns the base64 SHA-256 hash of the public key of a
+// certificate, as used in TLSOptions.PinnedPublicKeys.
+func PublicKeyPin(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
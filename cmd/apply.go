@@ -0,0 +1,382 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/MidVision/rd/client"
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var manifestPath, applyLogDir string
+var applyDryRun bool
+
+// A release manifest: the deployments of a release, kept e.g. in git.
+type releaseManifest struct {
+	Name string `yaml:"name" json:"name,omitempty"`
+	// Each step depends on the previous one, besides its own dependencies
+	Sequential bool           `yaml:"sequential" json:"sequential,omitempty"`
+	Steps      []*releaseStep `yaml:"steps" json:"steps"`
+}
+
+// A step of a release manifest: the deployment of a package of a project to some targets.
+type releaseStep struct {
+	Name    string `yaml:"name" json:"name"`
+	Project string `yaml:"project" json:"project"`
+	// The latest package of the project if empty
+	Package string `yaml:"package" json:"package,omitempty"`
+	// Target names or patterns, the default target of the project if empty
+	Targets []string `yaml:"targets" json:"targets"`
+	// Data dictionary files, relative to the manifest. The later ones override the previous ones.
+	DataDictionary []string `yaml:"dataDictionary" json:"dataDictionary,omitempty"`
+	DependsOn      []string `yaml:"depends_on" json:"dependsOn,omitempty"`
+	// The step runs after every step of the previous stage it depends on
+	Stage int `yaml:"-" json:"stage"`
+
+	// The targets and dictionary items resolved when validating the manifest
+	targets         []string
+	dictionaryItems []string
+}
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply -f MANIFEST_FILE",
+	Short: "Deploys the release described by a manifest file.",
+	Long: `Deploys the release described by a manifest file.
+
+The YAML manifest lists the steps of the release, each of them deploying
+a package of a project to one or more targets, e.g.:
+
+  name: release-2.4
+  steps:
+    - name: database
+      project: db-schema
+      package: "2.4.0"
+      targets: [db1.PROD.default]
+      dataDictionary: [prod.dict]
+    - name: backend
+      project: api
+      package: "2.4.1"
+      targets: ["api*.PROD.*"]
+      depends_on: [database]
+    - name: frontend
+      project: web
+      targets: ["web*.PROD.*"]
+      depends_on: [database]
+
+A step starts when all the steps it depends on finished successfully, so
+independent steps run concurrently: above 'backend' and 'frontend' run at the
+same time after 'database'. With 'sequential: true' each step also depends on
+the previous one. The steps of a failed step are skipped. Without a package,
+the latest one is deployed; without targets, the default target of the project.
+Target names may be patterns like 'web*.PROD.*', and the data dictionary files
+are relative to the manifest and have the syntax of the 'deploy' command ones.
+
+The manifest is validated against the server first: the projects, targets and
+packages must exist. Then the plan is printed and, without the '--dry-run'
+flag, executed, waiting for each deployment to finish. The command exits
+with the exit code of the worst outcome of the deployments.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if quiet {
+			os.Stdout = nil
+		}
+		// Check the correct number of arguments
+		if len(args) != 0 || manifestPath == "" {
+			cmd.Usage()
+			os.Exit(client.ExitUsage)
+		}
+
+		manifest, err := readManifest(manifestPath)
+		if err != nil {
+			printStdError("\n%v\n\n", err)
+			os.Exit(client.ExitUsage)
+		}
+
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
+
+		problems, err := validateRelease(cmd.Context(), manifest, filepath.Dir(manifestPath))
+		checkError(err)
+		if len(problems) > 0 {
+			printStdError("\nInvalid release manifest '%s':\n  %s\n\n", manifestPath, strings.Join(problems, "\n  "))
+			os.Exit(client.ExitNotFound)
+		}
+
+		// Print data in the selected output format.
+		// The machine-readable formats show the outcome of the deployments instead.
+		if applyDryRun || !machineOutput() {
+			printResult(&result{kind: "ReleasePlan", data: manifest.Steps, list: true,
+				columns: []*column{
+					newColumn("Stage", func(step *releaseStep) string { return strconv.Itoa(step.Stage) }),
+					newColumn("Step", func(step *releaseStep) string { return step.Name }),
+					newColumn("Project", func(step *releaseStep) string { return step.Project }),
+					newColumn("Package", func(step *releaseStep) string { return valueOr(step.Package, "(latest)") }),
+					newColumn("Targets", func(step *releaseStep) string { return strings.Join(step.targets, "\n") }),
+					newColumn("Depends on", func(step *releaseStep) string { return strings.Join(step.DependsOn, ", ") }),
+				}})
+		}
+		if applyDryRun {
+			return
+		}
+
+		fmt.Printf("Applying release '%s' with %d steps...\n", valueOr(manifest.Name, manifestPath), len(manifest.Steps))
+		deployments := applyRelease(cmd.Context(), manifest)
+		fmt.Println()
+		printDeployments(deployments, true)
+		os.Exit(worstExitCode(deployments))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(applyCmd)
+	applyCmd.Flags().StringVarP(&manifestPath, "filename", "f", "", "The release manifest file.")
+	applyCmd.Flags().BoolVar(&applyDryRun, "dry-run", false, "Validates the manifest and prints the plan without deploying anything.")
+	applyCmd.Flags().IntVar(&parallel, "parallel", 1, "Maximum number of deployments of a step running at a time.")
+	applyCmd.Flags().BoolVar(&failFast, "fail-fast", false, "Stops starting steps after the first one which fails. The running steps are still waited for. "+
+		"By default only the steps depending on a failed step are skipped.")
+	applyCmd.Flags().DurationVar(&approvalTimeout, "approval-timeout", 0, "Maximum duration to wait for each deployment to be approved, 0 means no limit.")
+	applyCmd.Flags().BoolVar(&expandDictionaryEnv, "expand-env", false, "Replaces the ${VAR} references to environment variables in the data dictionary values.")
+	applyCmd.Flags().StringVarP(&applyLogDir, "logdir", "l", "", "Saves the log of each deployment into this directory. "+
+		"Without a value ('-l' or '--logdir') they are saved into the current directory.")
+	applyCmd.Flags().Lookup("logdir").NoOptDefVal = "."
+}
+
+// Returns a value, or a replacement if it is empty.
+func valueOr(value, empty string) string {
+	if value == "" {
+		return empty
+	}
+	return value
+}
+
+// Reads a release manifest, rejecting unknown fields to catch misspelled ones.
+func readManifest(path string) (*releaseManifest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	manifest := &releaseManifest{}
+	if err := decoder.Decode(manifest); err != nil {
+		return nil, fmt.Errorf("Invalid release manifest '%s': %v", path, err)
+	}
+	if problems := checkSteps(manifest); len(problems) > 0 {
+		return nil, fmt.Errorf("Invalid release manifest '%s':\n  %s", path, strings.Join(problems, "\n  "))
+	}
+	return manifest, nil
+}
+
+// Checks the steps of a manifest and their dependencies, without the server,
+// and sets the stage of each step. Returns the problems found.
+func checkSteps(manifest *releaseManifest) []string {
+	if len(manifest.Steps) == 0 {
+		return []string{"The manifest has no steps"}
+	}
+	var problems []string
+	steps := map[string]*releaseStep{}
+	for i, step := range manifest.Steps {
+		if step.Name == "" {
+			step.Name = strconv.Itoa(i + 1)
+		}
+		if steps[step.Name] != nil {
+			problems = append(problems, fmt.Sprintf("Duplicated step '%s'", step.Name))
+		}
+		steps[step.Name] = step
+		if step.Project == "" {
+			problems = append(problems, fmt.Sprintf("Step '%s' has no project", step.Name))
+		}
+		if manifest.Sequential && i > 0 && !slices.Contains(step.DependsOn, manifest.Steps[i-1].Name) {
+			step.DependsOn = append([]string{manifest.Steps[i-1].Name}, step.DependsOn...)
+		}
+	}
+	for _, step := range manifest.Steps {
+		for _, dependency := range step.DependsOn {
+			if steps[dependency] == nil {
+				problems = append(problems, fmt.Sprintf("Step '%s' depends on unknown step '%s'", step.Name, dependency))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	// Each step is one stage after its latest dependency: a step found again
+	// while its stage is being resolved is part of a dependency cycle
+	resolving := map[string]bool{}
+	var resolve func(step *releaseStep) error
+	resolve = func(step *releaseStep) error {
+		if step.Stage > 0 {
+			return nil
+		}
+		if resolving[step.Name] {
+			return fmt.Errorf("Dependency cycle involving step '%s'", step.Name)
+		}
+		resolving[step.Name] = true
+		stage := 1
+		for _, dependency := range step.DependsOn {
+			if err := resolve(steps[dependency]); err != nil {
+				return err
+			}
+			stage = max(stage, steps[dependency].Stage+1)
+		}
+		step.Stage = stage
+		return nil
+	}
+	for _, step := range manifest.Steps {
+		if err := resolve(step); err != nil {
+			return []string{err.Error()}
+		}
+	}
+	return nil
+}
+
+// Validates the steps of a manifest against the server: the projects,
+// targets and packages must exist. Resolves the targets and reads the data
+// dictionary files of each step, relative to 'baseDir'. Returns the problems
+// found, or an error if the server could not be queried.
+func validateRelease(ctx context.Context, manifest *releaseManifest, baseDir string) ([]string, error) {
+	projects, err := rdClient.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	projectNames := map[string]bool{}
+	for _, project := range projects {
+		projectNames[project.Name] = true
+	}
+
+	var problems []string
+	packages := map[string][]string{}
+	for _, step := range manifest.Steps {
+		if !projectNames[step.Project] {
+			problems = append(problems, fmt.Sprintf("Step '%s': project '%s' not found", step.Name, step.Project))
+			continue
+		}
+		if err := resolveTargets(ctx, step); err != nil {
+			problems = append(problems, fmt.Sprintf("Step '%s': %v", step.Name, err))
+		}
+		if step.Package != "" {
+			if _, ok := packages[step.Project]; !ok {
+				if packages[step.Project], err = rdClient.ListPackages(ctx, step.Project); err != nil {
+					return nil, err
+				}
+			}
+			if !slices.Contains(packages[step.Project], step.Package) {
+				problems = append(problems, fmt.Sprintf("Step '%s': package '%s' of project '%s' not found", step.Name, step.Package, step.Project))
+			}
+		}
+		dictionary := &dataDictionary{expandEnv: expandDictionaryEnv}
+		for _, dictionaryPath := range step.DataDictionary {
+			if !filepath.IsAbs(dictionaryPath) {
+				dictionaryPath = filepath.Join(baseDir, dictionaryPath)
+			}
+			if err := dictionary.readFile(dictionaryPath); err != nil {
+				problems = append(problems, fmt.Sprintf("Step '%s': %v", step.Name, err))
+			}
+		}
+		step.dictionaryItems = dictionary.items()
+	}
+	return problems, nil
+}
+
+// Resolves the targets of a step, checking that they exist in its project.
+func resolveTargets(ctx context.Context, step *releaseStep) error {
+	if len(step.Targets) == 0 {
+		defaultTarget, err := rdClient.DefaultTarget(ctx, step.Project)
+		if err != nil {
+			return err
+		}
+		if defaultTarget == "" {
+			return fmt.Errorf("project '%s' has no default target", step.Project)
+		}
+		step.targets = []string{defaultTarget}
+		return nil
+	}
+	targets, err := expandTargets(ctx, step.Project, step.Targets)
+	if err != nil {
+		return err
+	}
+	projectTargets, err := rdClient.ListTargets(ctx, step.Project)
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		if !slices.Contains(projectTargets, target) {
+			return fmt.Errorf("target '%s' not found in project '%s'", target, step.Project)
+		}
+	}
+	step.targets = targets
+	return nil
+}
+
+// Deploys the steps of a validated manifest, each one when the steps it depends
+// on finished successfully, and returns the outcome of every deployment.
+func applyRelease(ctx context.Context, manifest *releaseManifest) []*targetDeployment {
+	// Closed to stop starting the remaining steps with the '--fail-fast' flag.
+	// The running steps are not cancelled, to report their outcome.
+	stopped := make(chan struct{})
+	var stop sync.Once
+
+	type stepRun struct {
+		done        chan struct{}
+		successful  bool
+		deployments []*targetDeployment
+	}
+	runs := map[string]*stepRun{}
+	for _, step := range manifest.Steps {
+		runs[step.Name] = &stepRun{done: make(chan struct{})}
+	}
+
+	var wg sync.WaitGroup
+	for _, step := range manifest.Steps {
+		run := runs[step.Name]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(run.done)
+			skipped := ""
+			for _, dependency := range step.DependsOn {
+				<-runs[dependency].done
+				if !runs[dependency].successful && skipped == "" {
+					skipped = fmt.Sprintf("Step '%s' did not finish successfully", dependency)
+				}
+			}
+			if skipped == "" && isClosed(stopped) {
+				skipped = "Not started after a failed step"
+			}
+			if skipped != "" {
+				for _, target := range step.targets {
+					run.deployments = append(run.deployments, &targetDeployment{Step: step.Name, Target: target,
+						Status: skippedStatus, Error: skipped, ExitCode: client.ExitCancelled})
+				}
+				return
+			}
+
+			fmt.Printf("Starting step '%s'...\n", step.Name)
+			spec := &deploySpec{project: step.Project, packageName: step.Package, dictionaryItems: step.dictionaryItems,
+				sync: true, logDir: applyLogDir, step: step.Name}
+			run.deployments = runDeployments(ctx, spec, step.targets)
+			run.successful = worstExitCode(run.deployments) == client.ExitOK
+			fmt.Printf("Step '%s' finished\n", step.Name)
+			if failFast && !run.successful {
+				stop.Do(func() { close(stopped) })
+			}
+		}()
+	}
+	wg.Wait()
+
+	var deployments []*targetDeployment
+	for _, step := range manifest.Steps {
+		deployments = append(deployments, runs[step.Name].deployments...)
+	}
+	return deployments
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"github.com/MidVision/rd/client"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckSteps(t *testing.T) {
+	manifest := &releaseManifest{Steps: []*releaseStep{
+		{Name: "db", Project: "db"},
+		{Name: "api", Project: "api", DependsOn: []string{"db"}},
+		{Name: "web", Project: "web", DependsOn: []string{"db", "api"}},
+		{Name: "docs", Project: "docs"},
+	}}
+	if problems := checkSteps(manifest); len(problems) > 0 {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+	for i, stage := range []int{1, 2, 3, 1} {
+		if manifest.Steps[i].Stage != stage {
+			t.Fatalf("expected stage %d for step '%s', got %d", stage, manifest.Steps[i].Name, manifest.Steps[i].Stage)
+		}
+	}
+
+	manifest = &releaseManifest{Sequential: true, Steps: []*releaseStep{{Project: "db"}, {Project: "api"}}}
+	if problems := checkSteps(manifest); len(problems) > 0 || manifest.Steps[1].Stage != 2 {
+		t.Fatalf("expected sequential steps: %v %+v", problems, manifest.Steps[1])
+	}
+
+	for _, steps := range [][]*releaseStep{
+		{{Name: "db", Project: "db"}, {Name: "db", Project: "api"}},
+		{{Name: "db"}},
+		{{Name: "db", Project: "db", DependsOn: []string{"cache"}}},
+		{{Name: "db", Project: "db", DependsOn: []string{"api"}}, {Name: "api", Project: "api", DependsOn: []string{"db"}}},
+		{},
+	} {
+		if problems := checkSteps(&releaseManifest{Steps: steps}); len(problems) == 0 {
+			t.Fatalf("expected problems for %+v", steps)
+		}
+	}
+}
+
+func TestApplyRelease(t *testing.T) {
+	newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		jobDetails := func(id, status string) {
+			w.Write([]byte(`<html><body><div/><div><div><ul><li><span>Job ID</span><span>` + id + `</span></li>` +
+				`<li><span>Job Status</span><span>` + status + `</span></li></ul></div></div></body></html>`))
+		}
+		switch {
+		case r.URL.Path == "/ws/project/list":
+			w.Write([]byte(`<Projects><Project><name>db</name></Project><Project><name>api</name></Project>` +
+				`<Project><name>web</name></Project><Project><name>docs</name></Project></Projects>`))
+		case strings.HasSuffix(r.URL.Path, "/list"):
+			project := strings.Split(r.URL.Path, "/")[3]
+			w.Write([]byte(`<html><body><div/><div><div><ul>` +
+				`<li><span>Item</span><span>` + project + `1.PROD.cfg</span></li>` +
+				`<li><span>Item</span><span>` + project + `2.PROD.cfg</span></li>` +
+				`<li><span>Item</span><span>1.0</span></li>` +
+				`</ul></div></div></body></html>`))
+		case strings.Contains(r.URL.Path, "/runjob/deploy/"):
+			// The deployments of the api fail
+			id := "1"
+			if strings.Contains(r.URL.Path, "/deploy/api") {
+				id = "100"
+			} else if strings.Contains(r.URL.Path, "/deploy/docs") && failFast {
+				// Still running when the api fails
+				time.Sleep(200 * time.Millisecond)
+			}
+			jobDetails(id, client.StatusQueued)
+		case r.URL.Path == "/ws/deployment/display/job/100":
+			jobDetails("100", client.StatusFailed)
+		default:
+			jobDetails("1", client.StatusCompleted)
+		}
+	})
+
+	dir := t.TempDir()
+	manifestFile := filepath.Join(dir, "release.yaml")
+	os.WriteFile(filepath.Join(dir, "prod.dict"), []byte("@@PORT@@=8080\n"), 0644)
+	os.WriteFile(manifestFile, []byte(`name: release
+steps:
+  - name: db
+    project: db
+    package: "1.0"
+    targets: [db1.PROD.cfg]
+    dataDictionary: [prod.dict]
+  - name: api
+    project: api
+    targets: ["api*.PROD.*"]
+    depends_on: [db]
+  - name: web
+    project: web
+    targets: [web1.PROD.cfg]
+    depends_on: [api]
+  - name: docs
+    project: docs
+    targets: [docs2.PROD.cfg]
+`), 0644)
+
+	manifest, err := readManifest(manifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems, err := validateRelease(context.Background(), manifest, dir)
+	if err != nil || len(problems) > 0 {
+		t.Fatalf("unexpected problems: %v (%v)", problems, err)
+	}
+	if strings.Join(manifest.Steps[1].targets, ",") != "api1.PROD.cfg,api2.PROD.cfg" || manifest.Steps[2].targets[0] != "web1.PROD.cfg" ||
+		strings.Join(manifest.Steps[0].dictionaryItems, ",") != "@@PORT@@=8080" {
+		t.Fatalf("unexpected resolved steps: %+v %+v %+v", manifest.Steps[0], manifest.Steps[1], manifest.Steps[2])
+	}
+
+	// The steps depending on a failed step are skipped, the independent ones run
+	statuses := map[string]string{}
+	for _, deployment := range applyRelease(context.Background(), manifest) {
+		statuses[deployment.Step+"/"+deployment.Target] = deployment.Status
+	}
+	for target, status := range map[string]string{
+		"db/db1.PROD.cfg": client.StatusCompleted, "api/api1.PROD.cfg": client.StatusFailed,
+		"web/web1.PROD.cfg": skippedStatus, "docs/docs2.PROD.cfg": client.StatusCompleted,
+	} {
+		if statuses[target] != status {
+			t.Fatalf("expected status %s for %s: %v", status, target, statuses)
+		}
+	}
+
+	// With '--fail-fast' the running steps finish, only the next ones are skipped
+	defer func() { failFast = false }()
+	failFast = true
+	deployments := map[string]*targetDeployment{}
+	for _, deployment := range applyRelease(context.Background(), manifest) {
+		deployments[deployment.Step+"/"+deployment.Target] = deployment
+	}
+	if docs := deployments["docs/docs2.PROD.cfg"]; docs.Status != client.StatusCompleted || docs.JobId != "1" ||
+		deployments["api/api1.PROD.cfg"].Status != client.StatusFailed || deployments["web/web1.PROD.cfg"].Status != skippedStatus {
+		t.Fatalf("unexpected deployments: %+v %+v", docs, deployments["web/web1.PROD.cfg"])
+	}
+
+	manifest.Steps[0].Package = "2.0"
+	manifest.Steps[3].Targets = []string{"docs3.PROD.cfg"}
+	if problems, err := validateRelease(context.Background(), manifest, dir); err != nil || len(problems) != 2 {
+		t.Fatalf("expected the missing package and target to be reported: %v (%v)", problems, err)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/MidVision/rd/client"
+	"github.com/spf13/cobra"
+	"os"
+	"strconv"
+)
+
+var approvalComment string
+
+// approvalCmd represents the approval command
+var approvalCmd = &cobra.Command{
+	Use:   "approval",
+	Short: "Manages the jobs waiting for an approval.",
+	Long: `Manages the jobs waiting for an approval, i.e. in the REQUESTED or
+REQUESTED_SCHEDULED states, which do not start until they are approved.`,
+}
+
+var approvalListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the jobs waiting for an approval.",
+	Long:  `Lists the jobs waiting for an approval.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if quiet {
+			os.Stdout = nil
+		}
+
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
+
+		// Perform the REST call to get the data
+		rdJobs, err := rdClient.ListPendingApprovals(cmd.Context())
+		checkError(err)
+
+		// Print data in the selected output format
+		printResult(&result{kind: "JobList", data: rdJobs, list: true, empty: "No jobs waiting for an approval",
+			columns: []*column{
+				newColumn("ID", func(job *client.Job) string { return strconv.Itoa(job.Id) }),
+				newColumn("Project", func(job *client.Job) string { return job.ProjectName }),
+				newColumn("Target", func(job *client.Job) string { return job.Target }),
+				wideColumn("Package", func(job *client.Job) string { return job.PackageName }),
+				newColumn("Status", func(job *client.Job) string { return job.Status }),
+				newColumn("Requested by", func(job *client.Job) string { return job.RequestedBy }),
+				wideColumn("Start date", func(job *client.Job) string { return job.StartDate }),
+			}})
+	},
+}
+
+var approvalApproveCmd = &cobra.Command{
+	Use:   "approve JOB_ID",
+	Short: "Approves a job waiting for an approval.",
+	Long:  `Approves a job waiting for an approval, so it starts or is scheduled.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		reviewJob(cmd, args, "approved", rdClient.ApproveJob)
+	},
+}
+
+var approvalRejectCmd = &cobra.Command{
+	Use:   "reject JOB_ID",
+	Short: "Rejects a job waiting for an approval.",
+	Long:  `Rejects a job waiting for an approval, so it never starts.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		reviewJob(cmd, args, "rejected", rdClient.RejectJob)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(approvalCmd)
+	approvalCmd.AddCommand(approvalListCmd)
+	approvalCmd.AddCommand(approvalApproveCmd)
+	approvalCmd.AddCommand(approvalRejectCmd)
+	approvalApproveCmd.Flags().StringVarP(&approvalComment, "comment", "c", "", "Comment recorded with the approval.")
+	approvalRejectCmd.Flags().StringVarP(&approvalComment, "comment", "c", "", "Comment recorded with the rejection, e.g. its reason.")
+	addListFlags(approvalListCmd)
+}
+
+// Approves or rejects the job given as argument and shows its details.
+func reviewJob(cmd *cobra.Command, args []string, action string,
+	review func(ctx context.Context, jobId, comment string) (client.JobDetails, error)) {
+	if quiet {
+		os.Stdout = nil
+	}
+	if len(args) != 1 {
+		cmd.Usage()
+		os.Exit(client.ExitUsage)
+	}
+
+	// Load the login session - initialize the rdClient struct
+	checkError(loadSession(cmd.Context(), rdClient))
+
+	jobDetails, err := review(cmd.Context(), args[0], approvalComment)
+	checkError(err)
+	fmt.Printf("\nJob '%s' %s.\n", args[0], action)
+	printResult(jobResult(jobDetails))
+}
@@ -0,0 +1,592 @@
+// Copyright © 2024 Rafael Ruiz Palacios <support@midvision.com>
+
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/MidVision/rd/client"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Files of a backup archive
+const (
+	backupProjectsDir      = "projects"
+	backupServersFile      = "servers.xml"
+	backupInstallationsDir = "installations"
+	backupJobPlansFile     = "jobPlans.xml"
+)
+
+// How the restore handles the projects which already exist in the server
+const (
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+	conflictRename    = "rename"
+)
+
+// Actions of a restore
+const (
+	restoreCreated     = "CREATED"
+	restoreOverwritten = "OVERWRITTEN"
+	restoreRenamed     = "RENAMED"
+	restoreSkipped     = "SKIPPED"
+	restoreFailed      = "FAILED"
+	restoreNotRestored = "NOT_RESTORED"
+)
+
+// Suffix of the name of the projects restored under a new name
+const restoredSuffix = "-restored"
+
+var backupOutputDir string
+var restoreProjects, restoreServers []string
+var restoreOnConflict string
+var restoreDryRun bool
+
+// The manifest of a backup archive: what it contains, with the checksums of its files.
+type backupManifest struct {
+	Server    string        `json:"server"`
+	CreatedAt time.Time     `json:"createdAt"`
+	Projects  []string      `json:"projects"`
+	Servers   []string      `json:"servers"`
+	Files     []*backupFile `json:"files"`
+}
+
+// A file of a backup archive.
+type backupFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// A created backup archive.
+type backupArchive struct {
+	File   string `json:"file"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	*backupManifest
+}
+
+// A resource of a restore and what was done with it.
+type restoredItem struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// The new name of a renamed project
+	RestoredAs string `json:"restoredAs,omitempty"`
+	Action     string `json:"action"`
+	Error      string `json:"error,omitempty"`
+	ExitCode   int    `json:"exitCode"`
+}
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Backs up and restores the configuration of a RapidDeploy server.",
+	Long: `Backs up the configuration of a RapidDeploy server into a single archive, e.g.
+before an upgrade, and restores it.`,
+}
+
+var backupCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Backs up the configuration of the RapidDeploy server.",
+	Long: `Writes a backup archive of the RapidDeploy server into the current directory, or
+into the '--output-dir' directory, which is created if needed. The archive is a
+ZIP file named after its date, e.g. 'rd-backup-20240131-120000.zip', with:
+
+  ` + backupProjectsDir + `/PROJECT.zip        The export of every project, up to '--parallel'
+                              at a time.
+  ` + backupServersFile + `                 The server definitions.
+  ` + backupInstallationsDir + `/SERVER.xml    The installations of each server, by server
+                              display name.
+  ` + backupJobPlansFile + `                The job plans.
+  ` + exportManifestFile + `               The contents of the archive with the size and
+                              SHA-256 checksum of each file.
+
+The SHA-256 checksum of the archive itself is written next to it, into a
+'.sha256' file in the format of the 'sha256sum' tool. If anything cannot be
+backed up, no archive is written.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if quiet {
+			os.Stdout = nil
+		}
+		// Check the correct number of arguments
+		if len(args) != 0 {
+			cmd.Usage()
+			os.Exit(client.ExitUsage)
+		}
+
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
+
+		if err := os.MkdirAll(backupOutputDir, 0755); err != nil {
+			printStdError("\nUnable to create directory: %s\n", backupOutputDir)
+			printStdError("%v\n\n", err)
+			os.Exit(1)
+		}
+		archive, err := createBackup(cmd.Context(), backupOutputDir)
+		var exportErr *backupExportError
+		if errors.As(err, &exportErr) {
+			exitCodes := make([]int, len(exportErr.projects))
+			for i, project := range exportErr.projects {
+				printStdError("\nUnable to export project '%s': %s", project.Name, project.Error)
+				exitCodes[i] = project.ExitCode
+			}
+			printStdError("\n\nNo backup written.\n\n")
+			os.Exit(client.WorstExitCode(exitCodes...))
+		}
+		checkError(err)
+
+		if machineOutput() {
+			printResult(&result{kind: "Backup", data: archive,
+				header: []string{"File", "Size", "SHA-256", "Projects", "Servers"},
+				rows: [][]string{{archive.File, strconv.FormatInt(archive.Size, 10), archive.SHA256,
+					strconv.Itoa(len(archive.Projects)), strconv.Itoa(len(archive.Servers))}}})
+			return
+		}
+		fmt.Println()
+		fmt.Printf("Backup file: %s\n", archive.File)
+		fmt.Printf("SHA-256: %s\n", archive.SHA256)
+		fmt.Printf("%d projects and %d servers backed up.\n", len(archive.Projects), len(archive.Servers))
+		fmt.Println()
+	},
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore BACKUP_FILE_PATH",
+	Short: "Restores a backup into the RapidDeploy server.",
+	Long: `Restores the projects of a backup archive written by the 'backup create'
+command. The checksums of the archive are verified before restoring anything.
+
+The whole backup is restored, unless some projects or servers are selected with
+the '--projects' and '--servers' flags. RapidDeploy has no web services to create
+servers, installations or job plans, so they are listed as ` + restoreNotRestored + `
+to be restored by hand from the files of the archive:
+
+  - When the whole backup is restored, with a warning. The command does not
+    fail because of them.
+  - When the servers are selected with '--servers', as a failure, along with
+    their installations.
+
+The '--on-conflict' flag sets what is done with the projects which already
+exist:
+
+  skip        They are left as they are.
+  overwrite   They are replaced with the ones of the backup.
+  rename      They are restored with the '` + restoredSuffix + `' suffix, or '` + restoredSuffix + `-2',
+              etc. if that name is also taken.
+
+With the '--dry-run' flag only the actions are shown. The command exits with
+the exit code of the worst outcome.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if quiet {
+			os.Stdout = nil
+		}
+		// Check the correct number of arguments
+		if len(args) != 1 {
+			cmd.Usage()
+			os.Exit(client.ExitUsage)
+		}
+		if !slices.Contains([]string{conflictSkip, conflictOverwrite, conflictRename}, restoreOnConflict) {
+			printStdError("\nInvalid conflict handling '%s', it must be one of: skip, overwrite, rename\n\n", restoreOnConflict)
+			os.Exit(client.ExitUsage)
+		}
+
+		archive, manifest, err := openBackup(args[0])
+		if err != nil {
+			printStdError("\n%v\n\n", err)
+			os.Exit(1)
+		}
+		servers, projects := restoreServers, restoreProjects
+		everything := len(servers) == 0 && len(projects) == 0
+		if everything {
+			servers, projects = manifest.Servers, manifest.Projects
+		}
+		for _, name := range servers {
+			if !slices.Contains(manifest.Servers, name) {
+				printStdError("\nServer '%s' not found in the backup.\n\n", name)
+				os.Exit(client.ExitNotFound)
+			}
+		}
+		for _, name := range projects {
+			if !slices.Contains(manifest.Projects, name) {
+				printStdError("\nProject '%s' not found in the backup.\n\n", name)
+				os.Exit(client.ExitNotFound)
+			}
+		}
+
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
+
+		items, err := unrestorableItems(&archive.Reader, servers, everything)
+		checkError(err)
+		if everything && len(items) != 0 {
+			printStdError("WARNING: %d servers, installations and job plans cannot be restored, restore them by hand from the backup.\n", len(items))
+		}
+		restored, err := restoreBackup(cmd.Context(), &archive.Reader, projects)
+		checkError(err)
+		items = append(items, restored...)
+
+		// Print data in the selected output format
+		printResult(&result{kind: "RestoreList", data: items, list: true, empty: "Nothing to restore",
+			columns: []*column{
+				newColumn("Kind", func(item *restoredItem) string { return item.Kind }),
+				newColumn("Name", func(item *restoredItem) string { return item.Name }),
+				newColumn("Restored as", func(item *restoredItem) string { return item.RestoredAs }),
+				newColumn("Action", func(item *restoredItem) string { return item.Action }),
+				newColumn("Error", func(item *restoredItem) string { return item.Error }),
+			}})
+		exitCodes := make([]int, len(items))
+		for i, item := range items {
+			exitCodes[i] = item.ExitCode
+		}
+		archive.Close()
+		os.Exit(client.WorstExitCode(exitCodes...))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupCreateCmd)
+	backupCmd.AddCommand(backupRestoreCmd)
+	backupCreateCmd.Flags().StringVar(&backupOutputDir, "output-dir", ".", "Directory to write the backup archive into. It is created if it does not exist.")
+	backupCreateCmd.Flags().IntVar(&exportParallel, "parallel", 4, "Maximum number of projects exported at a time.")
+	backupRestoreCmd.Flags().StringSliceVar(&restoreProjects, "projects", nil, "Restores only these comma-separated projects.")
+	backupRestoreCmd.Flags().StringSliceVar(&restoreServers, "servers", nil, "Restores only these comma-separated servers, by display name. They cannot be restored by this command and are reported as failures.")
+	backupRestoreCmd.Flags().StringVar(&restoreOnConflict, "on-conflict", conflictSkip, "What to do with the projects which already exist: skip, overwrite or rename.")
+	backupRestoreCmd.Flags().BoolVar(&restoreDryRun, "dry-run", false, "Shows the actions without restoring anything.")
+}
+
+// The projects which could not be exported into a backup.
+type backupExportError struct {
+	projects []*exportedProject
+}
+
+func (e *backupExportError) Error() string {
+	return fmt.Sprintf("Unable to export %d projects", len(e.projects))
+}
+
+// Backs up the configuration of the server into a new archive in a directory.
+func createBackup(ctx context.Context, outputDir string) (*backupArchive, error) {
+	projects, err := rdClient.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	servers, err := rdClient.ListServers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	installations := make([][]*client.Environment, len(servers))
+	for i, server := range servers {
+		if installations[i], err = rdClient.ListInstallations(ctx, server.Displayname); err != nil {
+			return nil, err
+		}
+	}
+	jobPlans, err := rdClient.ListJobPlans(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Export the projects into a temporary directory, then copy them into the archive
+	exportDir, err := os.MkdirTemp("", "rd-backup-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(exportDir)
+	projectNames := make([]string, len(projects))
+	for i, project := range projects {
+		projectNames[i] = project.Name
+	}
+	exported := exportProjects(ctx, projectNames, exportDir)
+	var failed []*exportedProject
+	for _, project := range exported {
+		if project.Error != "" {
+			failed = append(failed, project)
+		}
+	}
+	if len(failed) != 0 {
+		return nil, &backupExportError{failed}
+	}
+
+	manifest := &backupManifest{Server: rdClient.BaseUrl.String(), CreatedAt: time.Now().UTC(), Projects: projectNames}
+	file := filepath.Join(outputDir, "rd-backup-"+manifest.CreatedAt.Local().Format("20060102-150405")+".zip")
+	tmpFile := file + ".tmp"
+	out, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create file: %s: %v", tmpFile, err)
+	}
+	defer os.Remove(tmpFile)
+	writer := &backupWriter{zip: zip.NewWriter(out), manifest: manifest}
+
+	for _, project := range exported {
+		if err := writer.addFile(path.Join(backupProjectsDir, project.File), filepath.Join(exportDir, project.File)); err != nil {
+			out.Close()
+			return nil, err
+		}
+	}
+	for i, server := range servers {
+		manifest.Servers = append(manifest.Servers, server.Displayname)
+		environments := &client.Environments{Environment: installations[i]}
+		if err := writer.addXML(path.Join(backupInstallationsDir, fileName(server.Displayname)+".xml"), environments); err != nil {
+			out.Close()
+			return nil, err
+		}
+	}
+	if err := writer.addXML(backupServersFile, &client.Servers{Server: servers}); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := writer.addXML(backupJobPlansFile, &client.JobPlans{JobPlan: jobPlans}); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := writer.close(); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(tmpFile)
+	if err != nil {
+		return nil, err
+	}
+	checksum := sha256.Sum256(content)
+	archive := &backupArchive{File: file, Size: int64(len(content)), SHA256: hex.EncodeToString(checksum[:]), backupManifest: manifest}
+	if err := os.Rename(tmpFile, file); err != nil {
+		return nil, err
+	}
+	checksumFile := file + ".sha256"
+	if err := os.WriteFile(checksumFile, []byte(archive.SHA256+"  "+filepath.Base(file)+"\n"), 0644); err != nil {
+		return nil, fmt.Errorf("Unable to create file: %s: %v", checksumFile, err)
+	}
+	return archive, nil
+}
+
+// Writes the files of a backup archive, recording their checksums in its manifest.
+type backupWriter struct {
+	zip      *zip.Writer
+	manifest *backupManifest
+}
+
+func (w *backupWriter) add(name string, content io.Reader) error {
+	entry, err := w.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: w.manifest.CreatedAt})
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(entry, hash), content)
+	if err != nil {
+		return err
+	}
+	w.manifest.Files = append(w.manifest.Files, &backupFile{Path: name, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))})
+	return nil
+}
+
+func (w *backupWriter) addFile(name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return w.add(name, file)
+}
+
+func (w *backupWriter) addXML(name string, data any) error {
+	content, err := xml.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return w.add(name, bytes.NewReader(append([]byte(xml.Header), content...)))
+}
+
+// Writes the manifest as the last file of the archive.
+func (w *backupWriter) close() error {
+	content, err := json.MarshalIndent(w.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	entry, err := w.zip.CreateHeader(&zip.FileHeader{Name: exportManifestFile, Method: zip.Deflate, Modified: w.manifest.CreatedAt})
+	if err != nil {
+		return err
+	}
+	if _, err := entry.Write(append(content, '\n')); err != nil {
+		return err
+	}
+	return w.zip.Close()
+}
+
+// Opens a backup archive and verifies its checksums: the one of the '.sha256'
+// file next to it, if any, and the ones of its manifest.
+func openBackup(file string) (*zip.ReadCloser, *backupManifest, error) {
+	if sum, err := os.ReadFile(file + ".sha256"); err == nil {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		checksum := sha256.Sum256(content)
+		if fields := strings.Fields(string(sum)); len(fields) == 0 || fields[0] != hex.EncodeToString(checksum[:]) {
+			return nil, nil, fmt.Errorf("The SHA-256 checksum of the backup file '%s' does not match its '.sha256' file", file)
+		}
+	}
+	archive, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid backup file '%s': %v", file, err)
+	}
+	manifest := &backupManifest{}
+	content, err := readBackupFile(&archive.Reader, exportManifestFile)
+	if err == nil {
+		err = json.Unmarshal(content, manifest)
+	}
+	if err != nil {
+		archive.Close()
+		return nil, nil, fmt.Errorf("Invalid backup file '%s': %v", file, err)
+	}
+	for _, backupFile := range manifest.Files {
+		content, err := readBackupFile(&archive.Reader, backupFile.Path)
+		if err != nil {
+			archive.Close()
+			return nil, nil, fmt.Errorf("Invalid backup file '%s': %v", file, err)
+		}
+		checksum := sha256.Sum256(content)
+		if int64(len(content)) != backupFile.Size || hex.EncodeToString(checksum[:]) != backupFile.SHA256 {
+			archive.Close()
+			return nil, nil, fmt.Errorf("Invalid backup file '%s': the size or the SHA-256 checksum of '%s' does not match the manifest", file, backupFile.Path)
+		}
+	}
+	return archive, manifest, nil
+}
+
+// Returns the content of a file of a backup archive.
+func readBackupFile(archive *zip.Reader, name string) ([]byte, error) {
+	entry, err := archive.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer entry.Close()
+	return io.ReadAll(entry)
+}
+
+// Restores projects of a backup archive, handling the conflicts with the
+// '--on-conflict' flag, and returns what was done.
+func restoreBackup(ctx context.Context, archive *zip.Reader, projectNames []string) ([]*restoredItem, error) {
+	if len(projectNames) == 0 {
+		return nil, nil
+	}
+	projects, err := rdClient.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existing := map[string]bool{}
+	for _, project := range projects {
+		existing[project.Name] = true
+	}
+	var items []*restoredItem
+	for _, name := range projectNames {
+		items = append(items, restoreProject(ctx, archive, name, existing))
+	}
+	return items, nil
+}
+
+// Returns the servers of a backup archive and their installations as not
+// restored, along with the job plans if the whole backup is restored, as
+// RapidDeploy has no web services to create them. They are failures only if
+// the servers were selected.
+func unrestorableItems(archive *zip.Reader, serverNames []string, everything bool) ([]*restoredItem, error) {
+	var items []*restoredItem
+	notRestored := func(kind, name, file string) {
+		item := &restoredItem{Kind: kind, Name: name, Action: restoreNotRestored,
+			Error: fmt.Sprintf("No web service to create it, restore it by hand from '%s'", file)}
+		if !everything {
+			item.ExitCode = client.ExitError
+		}
+		items = append(items, item)
+	}
+	for _, server := range serverNames {
+		notRestored("Server", server, backupServersFile)
+	}
+	for _, server := range serverNames {
+		file := path.Join(backupInstallationsDir, fileName(server)+".xml")
+		content, err := readBackupFile(archive, file)
+		if err != nil {
+			return nil, err
+		}
+		environments := new(client.Environments)
+		if err := xml.Unmarshal(content, environments); err != nil {
+			return nil, fmt.Errorf("Invalid installations file '%s' in the backup: %v", file, err)
+		}
+		for _, environment := range environments.Environment {
+			notRestored("Installation", server+"/"+environment.Name, file)
+		}
+	}
+	if !everything {
+		return items, nil
+	}
+	content, err := readBackupFile(archive, backupJobPlansFile)
+	if err != nil {
+		return nil, err
+	}
+	jobPlans := new(client.JobPlans)
+	if err := xml.Unmarshal(content, jobPlans); err != nil {
+		return nil, fmt.Errorf("Invalid job plans file in the backup: %v", err)
+	}
+	for _, jobPlan := range jobPlans.JobPlan {
+		notRestored("JobPlan", jobPlan.Name, backupJobPlansFile)
+	}
+	return items, nil
+}
+
+// Restores a project, unless it exists and is neither overwritten nor renamed.
+// The names of the restored projects are added to 'existing'.
+func restoreProject(ctx context.Context, archive *zip.Reader, name string, existing map[string]bool) *restoredItem {
+	item := &restoredItem{Kind: "Project", Name: name, Action: restoreCreated}
+	fail := func(err error) *restoredItem {
+		item.Action, item.ExitCode = restoreFailed, exitCode(err)
+		item.Error = strings.ReplaceAll(err.Error(), "\n", " ")
+		return item
+	}
+	content, err := readBackupFile(archive, path.Join(backupProjectsDir, fileName(name)+".zip"))
+	if err != nil {
+		return fail(err)
+	}
+	if existing[name] {
+		switch restoreOnConflict {
+		case conflictOverwrite:
+			item.Action = restoreOverwritten
+		case conflictRename:
+			item.Action, item.RestoredAs = restoreRenamed, name+restoredSuffix
+			for i := 2; existing[item.RestoredAs]; i++ {
+				item.RestoredAs = name + restoredSuffix + "-" + strconv.Itoa(i)
+			}
+			if content, err = renameProjectArchive(content, item.RestoredAs); err != nil {
+				return fail(err)
+			}
+		default:
+			item.Action = restoreSkipped
+			return item
+		}
+	}
+	existing[valueOr(item.RestoredAs, name)] = true
+	if restoreDryRun {
+		return item
+	}
+	logger.Debug("Restoring project", "project", name, "action", item.Action, "restored_as", item.RestoredAs)
+	if err := rdClient.Import(ctx, content); err != nil {
+		return fail(err)
+	}
+	return item
+}
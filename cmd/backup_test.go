@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"github.com/MidVision/rd/client"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBackup(t *testing.T) {
+	var imported []string
+	newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/ws/project/list":
+			w.Write([]byte("<Projects><Project><name>app</name></Project><Project><name>db</name></Project></Projects>"))
+		case r.URL.Path == "/ws/server/list":
+			w.Write([]byte("<Servers><Server><displayname>Web</displayname><hostname>web01</hostname></Server><Server><displayname>DB</displayname><hostname>db01</hostname></Server></Servers>"))
+		case r.URL.Path == "/ws/environment/Web/list" || r.URL.Path == "/ws/environment/DB/list":
+			w.Write([]byte("<Environments><environment><name>INST</name></environment></Environments>"))
+		case r.URL.Path == "/ws/deployment/jobPlan/list":
+			w.Write([]byte("<JobPlans><JobPlan><id>1</id><name>nightly</name></JobPlan></JobPlans>"))
+		case strings.HasSuffix(r.URL.Path, "/export"):
+			name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/ws/project/"), "/export")
+			w.Write(zipArchive(t, map[string]string{name + ".xml": "<Project><enabled>true</enabled><name>" + name + "</name></Project>"}))
+		case r.URL.Path == "/ws/project/import":
+			content, _ := io.ReadAll(r.Body)
+			name, err := projectArchiveName(content)
+			if err != nil {
+				t.Errorf("unexpected archive imported: %v", err)
+			}
+			imported = append(imported, name)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	archive, err := createBackup(context.Background(), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Projects) != 2 || len(archive.Servers) != 2 || len(archive.Files) != 6 {
+		t.Fatalf("unexpected backup: %+v", archive.backupManifest)
+	}
+	if sum, _ := os.ReadFile(archive.File + ".sha256"); !strings.HasPrefix(string(sum), archive.SHA256+"  rd-backup-") {
+		t.Fatalf("unexpected checksum file: %s", sum)
+	}
+
+	reader, manifest, err := openBackup(archive.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if manifest.Server != rdClient.BaseUrl.String() || manifest.Projects[1] != "db" || manifest.Servers[0] != "Web" {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+
+	// The servers, their installations and the job plans cannot be restored:
+	// only a warning when restoring everything, a failure for selected servers
+	items, err := unrestorableItems(&reader.Reader, manifest.Servers, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 5 || items[2].Name != "Web/INST" || items[4].Kind != "JobPlan" || items[4].ExitCode != client.ExitOK {
+		t.Fatalf("unexpected items not restored: %+v", items)
+	}
+	items, err = unrestorableItems(&reader.Reader, []string{"DB"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) == 0 || items[0].Name != "DB" || items[len(items)-1].Kind == "JobPlan" || items[0].ExitCode != client.ExitError {
+		t.Fatalf("unexpected servers not restored: %+v", items)
+	}
+
+	// Both projects exist: rename the project
+	defer func(onConflict string) { restoreOnConflict = onConflict }(restoreOnConflict)
+	restoreOnConflict = conflictRename
+	items, err = restoreBackup(context.Background(), &reader.Reader, []string{"app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Action != restoreRenamed || items[0].RestoredAs != "app-restored" {
+		t.Fatalf("unexpected restore: %+v", items[0])
+	}
+	if len(imported) != 1 || imported[0] != "app-restored" {
+		t.Fatalf("unexpected imports: %v", imported)
+	}
+
+	restoreOnConflict = conflictOverwrite
+	items, _ = restoreBackup(context.Background(), &reader.Reader, []string{"db"})
+	if len(items) != 1 || items[0].Action != restoreOverwritten || imported[1] != "db" {
+		t.Fatalf("unexpected restore: %+v %v", items[0], imported)
+	}
+
+	// A modified archive is rejected
+	os.WriteFile(archive.File+".sha256", []byte("0000  backup.zip\n"), 0644)
+	if _, _, err := openBackup(archive.File); err == nil {
+		t.Fatal("expected the checksum of the archive to be verified")
+	}
+}
+
+func TestRenameProjectArchive(t *testing.T) {
+	content := zipArchive(t, map[string]string{
+		"app/readme.txt": "readme",
+		"app/app.xml":    "<?xml version=\"1.0\"?>\n<Project>\n  <owner><name>admin</name></owner>\n  <name>app</name>\n</Project>",
+	})
+	renamed, err := renameProjectArchive(content, "app & co")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, err := projectArchiveName(renamed); err != nil || name != "app & co" {
+		t.Fatalf("unexpected project name %q: %v", name, err)
+	}
+	if _, err := renameProjectArchive(zipArchive(t, map[string]string{"readme.txt": "readme"}), "x"); err == nil {
+		t.Fatal("expected an error for an archive without a project descriptor")
+	}
+}
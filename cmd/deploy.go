@@ -1,35 +1,62 @@
 // Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
 
-// TODO: provide log file path as a flag.
 // FIXME: "Configuration Name" not being shown in deployment summary.
 
 package cmd
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/xml"
+	"context"
+	"errors"
 	"fmt"
-	"github.com/olekukonko/tablewriter"
+	"github.com/MidVision/rd/client"
 	"github.com/spf13/cobra"
-	"net/http"
-	"net/url"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 )
 
-var deployPackage, targetName, dataDictionaryPath string
-var synchronous, logfile bool
+var deployPackage string
+var targetNames, dataDictionaryPaths []string
+var expandDictionaryEnv bool
+var synchronous, follow, highlight bool
+var logfile string
+var approvalTimeout time.Duration
 
 // deployCmd represents the deploy command
 var deployCmd = &cobra.Command{
-	Use:   "deploy PROJECT_NAME [TARGET_NAME] [@@DICTIONARY_KEY_1@@=DICTIONARY_VALUE_1 @@DICTIONARY_KEY_2@@=DICTIONARY_VALUE_2 ...]",
+	Use:   "deploy PROJECT_NAME [TARGET_NAME...] [@@DICTIONARY_KEY_1@@=DICTIONARY_VALUE_1 @@DICTIONARY_KEY_2@@=DICTIONARY_VALUE_2 ...]",
 	Short: "Deploys a RapidDeploy project to a specific target.",
 	Long: `Deploys a RapidDeploy project's deploymen package to a specific target (i.e. SERVER.INSTALLATION.CONFIGURATION).
 
-If no target name is specified the first one found with a 'localhost' hostname will be used.`,
+If no target name is specified the first one found with a 'localhost' hostname will be used.
+
+Several target names, or patterns such as 'web*.PROD.*' matched against the
+targets of the project, deploy the project to all the targets, up to
+'--parallel' at a time, and show a summary of the deployments. The command
+exits with the exit code of the worst outcome.
+
+With the '--at' or '--in' flags the deployment is scheduled to start later,
+the dates without a time zone are in local time.
+
+The data dictionary items are read from the '--dataDictionary' files in order,
+and then from the arguments, each one overriding the previous values of its
+key. The files are YAML or JSON maps of keys to values, by their extension, or
+'@@KEY@@=value' lines, where the keys may omit the '@@' and the lines starting
+with '#' are comments. The values of the lines and the arguments are:
+
+  value             taken as it is
+  @path/to/file     the content of the file, relative to the dictionary file
+  "value"           may contain '=', '#', new lines and the
+                    \n, \t, \" and \\ escapes
+  'value'           taken literally
+
+In YAML and JSON files the single-quoted values are literal and the other ones
+behave like the unquoted values.
+
+With the '--expand-env' flag the '${VAR}' and '${VAR:-default}' references in
+the values, except the single-quoted ones, are replaced by the environment
+variables ('$${' for '${'), and a variable without a default must be set. It
+is not the default, as the existing dictionary files may contain '${'.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if quiet {
 			os.Stdout = nil
@@ -39,94 +66,113 @@ If no target name is specified the first one found with a 'localhost' hostname w
 		resParse, dictionaryArguments := parseArguments(args)
 		if !resParse {
 			cmd.Usage()
-			os.Exit(1)
+			os.Exit(client.ExitUsage)
+		}
+
+		scheduledDate, err := parseSchedule(scheduleAt, scheduleIn, time.Now())
+		if err != nil {
+			printStdError("\n%v\n\n", err)
+			os.Exit(client.ExitUsage)
 		}
 
-		// Include data dictionary items from a file if provided
-		if dataDictionaryPath != "" {
-			if debug {
-				fmt.Printf("[DEBUG] Parsing data dictionary file at '%s'...\n", dataDictionaryPath)
+		// Merge the data dictionary items of the files, if provided, and the arguments
+		dictionary := &dataDictionary{expandEnv: expandDictionaryEnv}
+		for _, dataDictionaryPath := range dataDictionaryPaths {
+			logger.Debug("Parsing data dictionary file", "path", dataDictionaryPath)
+			if err := dictionary.readFile(dataDictionaryPath); err != nil {
+				printStdError("\n%v\n\n", err)
+				os.Exit(1)
 			}
-			err := parseDataDictionaryFile(dataDictionaryPath, &dictionaryArguments)
-			if err != nil {
+		}
+		for _, item := range dictionaryArguments {
+			if err := dictionary.readItem(item, "."); err != nil {
 				printStdError("\n%v\n\n", err)
 				os.Exit(1)
 			}
 		}
+		dictionaryArguments = dictionary.items()
 
-		// Load the login session file - initialize the rdClient struct
-		if err := rdClient.loadLoginFile(); err != nil {
-			printStdError("\n%v\n\n", err)
-			os.Exit(1)
+		multiTarget := len(targetNames) > 1 || len(targetNames) == 1 && isTargetPattern(targetNames[0])
+		if multiTarget && follow {
+			printStdError("\nThe '--follow' flag cannot be used to deploy to several targets.\n\n")
+			os.Exit(client.ExitUsage)
 		}
 
-		if targetName == "" {
-			targetName = getDefaultTargetName(projectName)
-		}
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
 
-		if debug {
-			fmt.Printf("[DEBUG] Deploying '%s' to '%s' with package '%s'...\n", projectName, targetName, deployPackage)
+		if multiTarget {
+			targets, err := expandTargets(cmd.Context(), projectName, targetNames)
+			checkError(err)
+			os.Exit(deployToTargets(cmd.Context(), targets, dictionaryArguments, scheduledDate))
 		}
 
-		targetStrip := strings.Split(targetName, ".")
-		if len(targetStrip) != 3 {
-			printStdError("\nInvalid target name '%s'\n", targetName)
-			printStdError("The target name has to include the server, the installation and the configuration names:\n")
-			printStdError("e.g. SERVER.INSTALLATION.CONFIGURATION\n\n")
-			os.Exit(1)
+		targetName := ""
+		if len(targetNames) == 1 {
+			targetName = targetNames[0]
 		}
-		serverName := targetStrip[0]
-		installName := targetStrip[1]
-		configName := targetStrip[2]
-
-		var urlBuffer bytes.Buffer
-		urlBuffer.WriteString("deployment/" + projectName + "/runjob/deploy/" + serverName + "/" + installName + "/" + configName +
-			"?packageName=" + deployPackage)
-		for _, dictionaryArg := range dictionaryArguments {
-			urlBuffer.WriteString("&dictionaryItem=" + url.QueryEscape(dictionaryArg))
+		if targetName == "" {
+			logger.Debug("Getting the default target", "project", projectName)
+			defaultTarget, err := rdClient.DefaultTarget(cmd.Context(), projectName)
+			checkError(err)
+			targetName = defaultTarget
 		}
 
-		resData, resCode, _ := rdClient.call(http.MethodPut, urlBuffer.String(), nil, "text/xml", false)
+		logger.Debug("Deploying", "project", projectName, "target", targetName, "package", deployPackage)
 
-		// Print deployment information in a table
-		var table *tablewriter.Table
-		if resCode == 200 {
-			table = tablewriter.NewWriter(os.Stdout)
-		} else {
-			table = tablewriter.NewWriter(os.Stderr)
+		jobDetails, err := rdClient.Deploy(cmd.Context(), projectName, targetName, deployPackage, dictionaryArguments, scheduledDate)
+		var notFoundErr *client.NotFoundError
+		if errors.As(err, &notFoundErr) {
+			printStdError("\nInvalid target name '%s'\n", targetName)
+			printStdError("Please check the server and the installation names.\n\n")
+			os.Exit(client.ExitNotFound)
 		}
-		table.SetAlignment(tablewriter.ALIGN_LEFT)
-		table.SetAutoMergeCells(true)
-		for _, message := range getResponseMessages(resData) {
-			if strings.Contains(message.Span[1], "No entity found") {
-				printStdError("\nInvalid target name '%s'\n", targetName)
-				printStdError("Please check the server and the installation names.\n\n")
-				os.Exit(1)
-			} else {
-				replacedTitle := strings.Replace(message.Span[0]+":", "Deployment Job ", "", -1)
-				table.Append([]string{replacedTitle, message.Span[1]})
+		checkError(err)
+
+		// Print deployment information in the selected output format.
+		// The machine-readable formats show the final state of the job instead.
+		if !machineOutput() {
+			res := jobResult(jobDetails)
+			for _, row := range res.rows {
+				row[0] = strings.Replace(row[0]+":", "Deployment Job ", "", -1)
+			}
+			printResult(res)
+			if !scheduledDate.IsZero() {
+				fmt.Printf("Deployment scheduled for %s\n\n", scheduledDate.Local().Format(scheduleDisplayLayout))
 			}
 		}
-		fmt.Println()
-		table.Render()
-		fmt.Println()
 
 		// Deploying project synchronously
-		if synchronous {
+		if synchronous || follow {
 			fmt.Println("Deploying project in synchronous mode...")
-			jobId := getJobId(resData)
-			checkSynchronousDeploy(jobId)
+			jobDetails = checkSynchronousDeploy(cmd.Context(), jobDetails.Id())
 			fmt.Println()
 		}
+
+		if machineOutput() {
+			printResult(jobResult(jobDetails))
+		}
+		if synchronous || follow {
+			os.Exit(client.StatusExitCode(jobDetails.Status()))
+		}
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(deployCmd)
 	deployCmd.Flags().BoolVarP(&synchronous, "sync", "s", false, "Waits for the deployment to finish.")
-	deployCmd.Flags().BoolVarP(&logfile, "logfile", "l", false, "Retrieves the deployment log file. It must be used with the 'sync' option.")
+	deployCmd.Flags().BoolVarP(&follow, "follow", "f", false, "Waits for the deployment to finish, printing the new lines of its log as they are written.")
+	deployCmd.Flags().BoolVar(&highlight, "highlight", false, "Highlights the ERROR and WARN lines of the log printed with the 'follow' option.")
+	deployCmd.Flags().StringVarP(&logfile, "logfile", "l", "", "Saves the deployment log into this file, or into a directory with its original name. "+
+		"Without a value ('-l' or '--logfile') it is saved into the current directory. It must be used with the 'sync' or 'follow' options.")
+	deployCmd.Flags().Lookup("logfile").NoOptDefVal = "."
+	addScheduleFlags(deployCmd, "Schedules the deployment")
+	deployCmd.Flags().DurationVar(&approvalTimeout, "approval-timeout", 0, "Maximum duration to wait for the deployment to be approved with the 'sync' option, 0 means no limit. "+
+		"When it is reached the command exits with code 14 (awaiting approval).")
 	deployCmd.Flags().StringVarP(&deployPackage, "package", "p", "", "The deployment package to deploy. It defaults to the latest version.")
-	deployCmd.Flags().StringVarP(&dataDictionaryPath, "dataDictionary", "a", "", "Path to a data dictionary file containing @@key@@=value pairs.")
+	deployCmd.Flags().StringArrayVarP(&dataDictionaryPaths, "dataDictionary", "a", nil, "Path to a data dictionary file containing @@key@@=value pairs, or a YAML or JSON map. "+
+		"It can be repeated, the later files override the values of the previous ones.")
+	deployCmd.Flags().BoolVar(&expandDictionaryEnv, "expand-env", false, "Replaces the ${VAR} references to environment variables in the data dictionary values.")
 }
 
 // Returns a boolean value showing if the arguments were properly
@@ -149,8 +195,8 @@ func parseArguments(args []string) (bool, []string) {
 	for i := 1; i < len(args); i++ {
 		if isDictionaryArg(args[i]) {
 			dictionaryItems = append(dictionaryItems, args[i])
-		} else if len(dictionaryItems) == 0 && targetName == "" {
-			targetName = args[i]
+		} else if len(dictionaryItems) == 0 {
+			targetNames = append(targetNames, args[i])
 		} else {
 			return false, []string{}
 		}
@@ -159,183 +205,29 @@ func parseArguments(args []string) (bool, []string) {
 }
 
 // Checks if 's' complies with the dictionary item argument syntax:
-// @@DICTIONARY_KEY@@=DICTIONARY_VALUE
+// @@DICTIONARY_KEY@@=DICTIONARY_VALUE, where the value may contain '='.
 func isDictionaryArg(s string) bool {
-	keyValuePair := strings.Split(s, "=")
-	if strings.Contains(s, "=") &&
-		len(keyValuePair) <= 2 &&
-		strings.HasPrefix(keyValuePair[0], "@@") &&
-		strings.HasSuffix(keyValuePair[0], "@@") {
-		return true
-	} else {
-		return false
-	}
+	key, _, found := strings.Cut(s, "=")
+	return found && len(key) > 4 && strings.HasPrefix(key, "@@") && strings.HasSuffix(key, "@@")
 }
 
-func getDefaultTargetName(projectName string) string {
-	if debug {
-		fmt.Printf("[DEBUG] Getting default target for project '%s'\n", projectName)
+// Waits for a deployment job to finish and returns its final details.
+func checkSynchronousDeploy(ctx context.Context, jobId string) client.JobDetails {
+	var follower *logFollower
+	if follow {
+		follower = &logFollower{out: os.Stdout, highlight: highlight}
 	}
-	resData, resCode, _ := rdClient.call(http.MethodGet, "project/"+projectName+"/list", nil, "text/xml", false)
-	rdTargets := new(Html)
-	err := xml.Unmarshal(resData, &rdTargets)
-	if err != nil {
-		printStdError("\n%v\n\n", err)
-		os.Exit(1)
+	jobDetails, err := waitForJob(ctx, jobId, "Deployment", follower, approvalTimeout)
+	checkError(err)
+	if client.IsPending(jobDetails.Status()) {
+		printStdError("\nThe deployment was not approved in %v.\n", approvalTimeout)
+		return jobDetails
 	}
-	if resCode != 200 {
-		table := tablewriter.NewWriter(os.Stderr)
-		table.SetAlignment(tablewriter.ALIGN_LEFT)
-		table.SetAutoMergeCells(true)
-		for _, message := range getResponseMessages(resData) {
-			replacedTitle := strings.Replace(message.Span[0]+":", "Deployment Job ", "", -1)
-			table.Append([]string{replacedTitle, message.Span[1]})
-		}
-		printStdError("\n")
-		table.Render()
-		printStdError("\n")
-		os.Exit(1)
+	if client.IsSuccessful(jobDetails.Status()) {
+		fmt.Printf("Project '%s' successfully deployed!\n", projectName)
 	}
-	if len(rdTargets.Body.Div[1].Div[0].Ul.Li) != 0 {
-		for _, target := range rdTargets.Body.Div[1].Div[0].Ul.Li {
-			targetName := target.Span[1]
-			if debug {
-				fmt.Printf("[DEBUG] Checking hostnames for target '%s'\n", targetName)
-			}
-			serverName := strings.Split(targetName, ".")[0]
-			resData, _, _ := rdClient.call(http.MethodGet, "server/"+serverName, nil, "text/xml", false)
-			server := new(Server)
-			err := xml.Unmarshal(resData, &server)
-			if err != nil {
-				printStdError("\n%v\n\n", err)
-				os.Exit(1)
-			}
-			if debug {
-				fmt.Printf("[DEBUG] Hostnames found: %s\n", server.Hostnames)
-			}
-			if strings.Contains(server.Hostname, "localhost") {
-				return targetName
-			}
-		}
-	}
-	return ""
-}
-
-func checkSynchronousDeploy(jobId string) {
-	logFilename := ""
-	timeToSleep := 0 * time.Second
-	jobRunning := true
-	for jobRunning {
-		time.Sleep(timeToSleep)
-		resData, _, _ := rdClient.call(http.MethodGet, "deployment/display/job/"+jobId, nil, "text/xml")
-		jobStatus := getjobStatus(resData)
-		fmt.Println("> Deployment status: " + jobStatus)
-		if jobStatus == "DEPLOYING" || jobStatus == "QUEUED" || jobStatus == "STARTING" || jobStatus == "EXECUTING" {
-			fmt.Println("  Deployment running, next check in 5 seconds...")
-			timeToSleep = 5 * time.Second
-		} else if jobStatus == "REQUESTED" || jobStatus == "REQUESTED_SCHEDULED" {
-			fmt.Println("  Deployment in a REQUESTED state. Approval may be required in RapidDeploy to continue with the execution, next check in 30 seconds...")
-			timeToSleep = 30 * time.Second
-		} else if jobStatus == "SCHEDULED" {
-			fmt.Println("  Deployment in a SCHEDULED state, the execution will start in a future date, next check in 5 minutes...")
-			fmt.Println("  > Printing out deployment details: ")
-			table := tablewriter.NewWriter(os.Stdout)
-			table.SetAlignment(tablewriter.ALIGN_LEFT)
-			table.SetAutoMergeCells(true)
-			for _, message := range getResponseMessages(resData) {
-				table.Append([]string{message.Span[0], message.Span[1]})
-			}
-			table.Render()
-			timeToSleep = 300 * time.Second
-		} else {
-			jobRunning = false
-			logFilename = getLogFilename(resData)
-			fmt.Println("Deployment finished with status: " + jobStatus)
-			if jobStatus != "FAILED" && jobStatus != "REJECTED" && jobStatus != "CANCELLED" && jobStatus != "UNEXECUTABLE" && jobStatus != "TIMEDOUT" && jobStatus != "UNKNOWN" {
-				fmt.Printf("Project '%s' successfully deployed!\n", projectName)
-			}
-		}
-	}
-	if logFilename != "" && logfile {
-		logFilePath, err := filepath.Abs(logFilename)
-		if err != nil {
-			printStdError("%v\n\n", err)
-			os.Exit(1)
-		}
-		resData, _, _ := rdClient.call(http.MethodGet, "deployment/showlog/job/"+jobId, nil, "text/xml")
-		err = os.WriteFile(logFilePath, resData, 0644)
-		if err != nil {
-			printStdError("\nUnable to create file: %s\n", logFilePath)
-			printStdError("%v\n\n", err)
-			os.Exit(1)
-		}
-		fmt.Printf("Log file available at '%s'\n", logFilePath)
-	}
-}
-
-func getResponseMessages(htmlContent []byte) []*Li {
-	htmlObject := new(Html)
-	err := xml.Unmarshal(htmlContent, &htmlObject)
-	if err != nil {
-		printStdError("\n%v\n\n", err)
-		os.Exit(1)
-	}
-	if len(htmlObject.Body.Div) < 2 ||
-		len(htmlObject.Body.Div[1].Div) < 1 ||
-		len(htmlObject.Body.Div[1].Div[0].Ul.Li) == 0 {
-		return []*Li{}
-	}
-	return htmlObject.Body.Div[1].Div[0].Ul.Li
-}
-
-func getjobStatus(htmlContent []byte) string {
-	for _, message := range getResponseMessages(htmlContent) {
-		if strings.Contains(message.Span[0], "Job Status") {
-			return message.Span[1]
-		}
-	}
-	return ""
-}
-
-func getJobId(htmlContent []byte) string {
-	for _, message := range getResponseMessages(htmlContent) {
-		if strings.Contains(message.Span[0], "Job ID") {
-			return message.Span[1]
-		}
-	}
-	return ""
-}
-
-func getLogFilename(htmlContent []byte) string {
-	for _, message := range getResponseMessages(htmlContent) {
-		if strings.Contains(message.Span[0], "File Path") {
-			return filepath.Base(message.Span[1])
-		}
-	}
-	return ""
-}
-
-func parseDataDictionaryFile(dataDictionaryFilePath string, dataDictionary *[]string) error {
-	file, err := os.Open(dataDictionaryFilePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		if !strings.Contains(line, "=") {
-			continue
-		}
-		dataDictionaryItem := strings.SplitN(line, "=", 2)
-		key := strings.TrimSpace(dataDictionaryItem[0])
-		value := strings.TrimSpace(dataDictionaryItem[1])
-
-		*dataDictionary = append(*dataDictionary, fmt.Sprintf("%s=%s", key, value))
+	if logfile != "" {
+		checkError(saveJobLog(ctx, jobId, jobLogPath(logfile, jobId, jobDetails)))
 	}
-	return scanner.Err()
+	return jobDetails
 }
@@ -0,0 +1,239 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/MidVision/rd/client"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status of a deployment not started because of the '--fail-fast' flag
+const skippedStatus = "SKIPPED"
+
+var parallel int
+var failFast bool
+
+// The deployment of a project to several targets.
+type deploySpec struct {
+	project         string
+	packageName     string
+	dictionaryItems []string
+	at              time.Time
+	// Waits for the deployments to finish
+	sync bool
+	// The directory to save the logs into with the 'sync' option, if any
+	logDir string
+	// The step of a release manifest the deployment belongs to, if any
+	step string
+}
+
+// The outcome of the deployment to one of several targets.
+type targetDeployment struct {
+	Step     string `json:"step,omitempty"`
+	Target   string `json:"target"`
+	JobId    string `json:"jobId,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Error    string `json:"error,omitempty"`
+	ExitCode int    `json:"exitCode"`
+}
+
+func init() {
+	deployCmd.Flags().IntVar(&parallel, "parallel", 1, "Maximum number of deployments running at a time when deploying to several targets.")
+	deployCmd.Flags().BoolVar(&failFast, "fail-fast", false, "Stops starting deployments after the first one which fails when deploying to several targets. "+
+		"The started deployments are still waited for. By default the remaining deployments continue.")
+}
+
+// Whether a target name is a pattern matching several targets.
+func isTargetPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// Returns the targets given by name or matching the given patterns, e.g.
+// 'web*.PROD.*', in the target list of the project. Each target is returned once.
+func expandTargets(ctx context.Context, projectName string, names []string) ([]string, error) {
+	var projectTargets, targets []string
+	found := map[string]bool{}
+	add := func(target string) {
+		if !found[target] {
+			found[target] = true
+			targets = append(targets, target)
+		}
+	}
+	for _, name := range names {
+		if !isTargetPattern(name) {
+			add(name)
+			continue
+		}
+		if projectTargets == nil {
+			var err error
+			if projectTargets, err = rdClient.ListTargets(ctx, projectName); err != nil {
+				return nil, err
+			}
+		}
+		matches := 0
+		for _, target := range projectTargets {
+			matched, err := path.Match(name, target)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid target pattern '%s': %v", name, err)
+			}
+			if matched {
+				add(target)
+				matches++
+			}
+		}
+		if matches == 0 {
+			return nil, fmt.Errorf("No targets of project '%s' match '%s'", projectName, name)
+		}
+	}
+	return targets, nil
+}
+
+// Deploys the project to several targets, prints the summary of the
+// deployments and returns the exit code of the worst outcome.
+func deployToTargets(ctx context.Context, targets, dictionaryItems []string, at time.Time) int {
+	fmt.Printf("Deploying project '%s' to %d targets...\n", projectName, len(targets))
+	spec := &deploySpec{project: projectName, packageName: deployPackage, dictionaryItems: dictionaryItems, at: at, sync: synchronous}
+	if synchronous {
+		spec.logDir = logfile
+	}
+	deployments := runDeployments(ctx, spec, targets)
+	fmt.Println()
+	printDeployments(deployments, false)
+	return worstExitCode(deployments)
+}
+
+// Prints the summary of several deployments, with the step of each one if 'steps' is set.
+func printDeployments(deployments []*targetDeployment, steps bool) {
+	var columns []*column
+	if steps {
+		columns = append(columns, newColumn("Step", func(deployment *targetDeployment) string { return deployment.Step }))
+	}
+	printResult(&result{kind: "DeploymentList", data: deployments, list: true,
+		columns: append(columns,
+			newColumn("Target", func(deployment *targetDeployment) string { return deployment.Target }),
+			newColumn("Job ID", func(deployment *targetDeployment) string { return deployment.JobId }),
+			newColumn("Status", func(deployment *targetDeployment) string { return deployment.Status }),
+			newColumn("Error", func(deployment *targetDeployment) string { return deployment.Error }),
+		)})
+}
+
+// Returns the exit code of the worst outcome of several deployments.
+func worstExitCode(deployments []*targetDeployment) int {
+	exitCodes := make([]int, len(deployments))
+	for i, deployment := range deployments {
+		exitCodes[i] = deployment.ExitCode
+	}
+	return client.WorstExitCode(exitCodes...)
+}
+
+// Deploys a project to several targets, up to '--parallel' at a time,
+// and returns the outcome of each deployment.
+func runDeployments(ctx context.Context, spec *deploySpec, targets []string) []*targetDeployment {
+	deployments := make([]*targetDeployment, len(targets))
+	if spec.logDir != "" {
+		if err := os.MkdirAll(spec.logDir, 0755); err != nil {
+			// Nothing is deployed if the logs cannot be saved
+			err = fmt.Errorf("Unable to create directory: %s: %v", spec.logDir, err)
+			for i, target := range targets {
+				deployments[i] = &targetDeployment{Step: spec.step, Target: target, Status: skippedStatus}
+				deployments[i].fail(err)
+			}
+			return deployments
+		}
+	}
+
+	// Closed to stop starting the remaining deployments with the '--fail-fast'
+	// flag. The started ones are not cancelled, to report their outcome.
+	stopped := make(chan struct{})
+	var stop sync.Once
+
+	sem := make(chan struct{}, max(parallel, 1))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		deployment := &targetDeployment{Step: spec.step, Target: target}
+		deployments[i] = deployment
+		select {
+		case sem <- struct{}{}:
+		case <-stopped:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			deployment.Status = skippedStatus
+			deployment.fail(ctx.Err())
+			continue
+		}
+		if isClosed(stopped) {
+			deployment.Status, deployment.ExitCode = skippedStatus, client.ExitCancelled
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			deployTarget(ctx, spec, deployment)
+			if failFast && deployment.ExitCode != client.ExitOK {
+				stop.Do(func() { close(stopped) })
+			}
+		}()
+	}
+	wg.Wait()
+	return deployments
+}
+
+// Whether a channel closed to signal an event is closed.
+func isClosed(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Deploys the project to the target of 'deployment' and, in synchronous
+// mode, waits for the deployment to finish, recording its outcome.
+func deployTarget(ctx context.Context, spec *deploySpec, deployment *targetDeployment) {
+	logger.Debug("Deploying", "project", spec.project, "target", deployment.Target, "package", spec.packageName, "step", spec.step)
+	kind := fmt.Sprintf("Deployment of '%s' to '%s'", spec.project, deployment.Target)
+	jobDetails, err := rdClient.Deploy(ctx, spec.project, deployment.Target, spec.packageName, spec.dictionaryItems, spec.at)
+	if err != nil {
+		deployment.fail(err)
+		return
+	}
+	deployment.JobId, deployment.Status = jobDetails.Id(), jobDetails.Status()
+	fmt.Printf("%s started with job ID %s\n", kind, deployment.JobId)
+	if !spec.sync {
+		return
+	}
+
+	jobDetails, err = waitForJob(ctx, deployment.JobId, kind, nil, approvalTimeout)
+	if err != nil {
+		deployment.fail(err)
+		return
+	}
+	deployment.Status = jobDetails.Status()
+	deployment.ExitCode = client.StatusExitCode(deployment.Status)
+	if spec.logDir != "" && !client.IsPending(deployment.Status) {
+		if err := saveJobLog(ctx, deployment.JobId, jobLogPath(spec.logDir, deployment.JobId, jobDetails)); err != nil {
+			// The deployment itself keeps its status
+			deployment.ExitCode = client.WorstExitCode(deployment.ExitCode, exitCode(err))
+			deployment.Error = "Unable to save the log: " + strings.ReplaceAll(err.Error(), "\n", " ")
+		}
+	}
+}
+
+func (deployment *targetDeployment) fail(err error) {
+	deployment.ExitCode = exitCode(err)
+	if errors.Is(err, context.Canceled) {
+		deployment.Error = "Interrupted"
+	} else {
+		deployment.Error = strings.ReplaceAll(err.Error(), "\n", " ")
+	}
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"github.com/MidVision/rd/client"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeployToTargets(t *testing.T) {
+	newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/ws/project/app/list":
+			w.Write([]byte(`<html><body><div/><div><div><ul>` +
+				`<li><span>Target</span><span>web1.PROD.cfg</span></li>` +
+				`<li><span>Target</span><span>web2.PROD.cfg</span></li>` +
+				`<li><span>Target</span><span>web3.TEST.cfg</span></li>` +
+				`</ul></div></div></body></html>`))
+		case strings.Contains(r.URL.Path, "/deploy/web1/"):
+			w.WriteHeader(http.StatusInternalServerError)
+		case strings.Contains(r.URL.Path, "/deploy/web2/") && parallel > 1:
+			// Still running when the deployment to web1 fails
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte(`<html><body><div/><div><div><ul><li><span>Job ID</span><span>8</span></li></ul></div></div></body></html>`))
+		default:
+			w.Write([]byte(`<html><body><div/><div><div><ul><li><span>Job ID</span><span>7</span></li></ul></div></div></body></html>`))
+		}
+	})
+	defer func() { projectName, parallel, failFast = "", 1, false }()
+	projectName = "app"
+
+	targets, err := expandTargets(context.Background(), "app", []string{"web*.PROD.*", "web1.PROD.cfg", "db.TEST.cfg"})
+	if err != nil || strings.Join(targets, ",") != "web1.PROD.cfg,web2.PROD.cfg,db.TEST.cfg" {
+		t.Fatalf("unexpected targets: %v (%v)", targets, err)
+	}
+	if _, err := expandTargets(context.Background(), "app", []string{"*.DEV.*"}); err == nil {
+		t.Fatal("expected an error for a pattern without matches")
+	}
+
+	// The worst outcome is reported, and the deployments continue by default
+	if exitCode := deployToTargets(context.Background(), targets, nil, time.Time{}); exitCode != client.ExitServerError {
+		t.Fatalf("expected a server error, got %v", exitCode)
+	}
+	failFast = true
+	deployments := runDeployments(context.Background(), &deploySpec{project: "app"}, targets)
+	if deployments[0].ExitCode != client.ExitServerError || deployments[1].Status != skippedStatus || deployments[2].Status != skippedStatus {
+		t.Fatalf("expected the deployments after the failed one to be skipped: %+v %+v %+v", deployments[0], deployments[1], deployments[2])
+	}
+
+	// The deployments started before the failure are not cancelled
+	parallel = 2
+	deployments = runDeployments(context.Background(), &deploySpec{project: "app"}, targets)
+	if deployments[0].ExitCode != client.ExitServerError || deployments[1].JobId != "8" || deployments[1].ExitCode != client.ExitOK || deployments[2].Status != skippedStatus {
+		t.Fatalf("expected only the deployments after the failed one to be skipped: %+v %+v %+v", deployments[0], deployments[1], deployments[2])
+	}
+	parallel = 1
+
+	// A log directory which cannot be created is reported without exiting
+	logFile := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(logFile, nil, 0644)
+	deployments = runDeployments(context.Background(), &deploySpec{project: "app", sync: true, logDir: filepath.Join(logFile, "logs")}, targets)
+	if len(deployments) != 3 || deployments[1].ExitCode != client.ExitError || !strings.Contains(deployments[1].Error, "Unable to create directory") {
+		t.Fatalf("expected the deployments to fail: %+v", deployments[1])
+	}
+}
+
+func TestWorstExitCode(t *testing.T) {
+	if code := client.WorstExitCode(client.ExitOK, client.ExitAwaitingApproval, client.ExitFailed, client.ExitCancelled); code != client.ExitFailed {
+		t.Fatalf("expected the failed exit code, got %v", code)
+	}
+	if code := client.WorstExitCode(); code != client.ExitOK {
+		t.Fatalf("expected success without exit codes, got %v", code)
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/MidVision/rd/client"
+	"go.yaml.in/yaml/v3"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Matches the '${VAR}' and '${VAR:-default}' references to environment
+// variables in the data dictionary values, and the '$${' escape.
+var envReference = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// The data dictionary of a deployment: the values of its @@KEY@@ tokens, in
+// the order they were first set. A value set again overrides the previous one.
+type dataDictionary struct {
+	keys   []string
+	values map[string]string
+	// Expands the environment variables of the values, with the '--expand-env' flag
+	expandEnv bool
+}
+
+func (d *dataDictionary) set(key, value string) {
+	if d.values == nil {
+		d.values = map[string]string{}
+	}
+	if _, ok := d.values[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.values[key] = value
+}
+
+// Returns the items of the dictionary in the @@KEY@@=value form of the deployments.
+func (d *dataDictionary) items() []string {
+	items := make([]string, len(d.keys))
+	for i, key := range d.keys {
+		items[i] = key + "=" + d.values[key]
+	}
+	return items
+}
+
+// Reads a data dictionary file: a YAML or JSON map by its extension, or
+// otherwise @@KEY@@=value lines. The files read from the values are relative
+// to the dictionary file.
+func (d *dataDictionary) readFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	baseDir := filepath.Dir(path)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		err = d.readMap(content, baseDir)
+	default:
+		err = d.readLines(string(content), baseDir)
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid data dictionary file '%s': %v", path, err)
+	}
+	return nil
+}
+
+// Reads an item given in the command line, with the syntax of the lines of the
+// dictionary files. The files read from the values are relative to 'baseDir'.
+func (d *dataDictionary) readItem(item, baseDir string) error {
+	key, value, _ := strings.Cut(item, "=")
+	value, err := d.value(value, baseDir)
+	if err != nil {
+		return fmt.Errorf("Invalid data dictionary item '%s': %v", key, err)
+	}
+	d.set(client.DictionaryKey(key), value)
+	return nil
+}
+
+// Reads @@KEY@@=value lines, ignoring the empty ones and the comments starting
+// with '#'. A quoted value may span several lines until its closing quote.
+func (d *dataDictionary) readLines(content, baseDir string) error {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		first := i
+		value = strings.TrimSpace(value)
+		for isQuoted(value) && !quoteClosed(value) && i+1 < len(lines) {
+			i++
+			value += "\n" + lines[i]
+		}
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("line %d: missing key", first+1)
+		}
+		value, err := d.value(value, baseDir)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", first+1, err)
+		}
+		d.set(client.DictionaryKey(key), value)
+	}
+	return nil
+}
+
+// Reads a YAML or JSON map of keys to scalar values. The single-quoted YAML
+// values are literal, the other ones are expanded like the unquoted values.
+func (d *dataDictionary) readMap(content []byte, baseDir string) error {
+	var document yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(content)).Decode(&document); err != nil {
+		return err
+	}
+	if len(document.Content) == 0 {
+		return nil
+	}
+	mapping := document.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a map of keys to values", mapping.Line)
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, node := mapping.Content[i], mapping.Content[i+1]
+		if node.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %d: the value of '%s' must be a string, number or boolean", node.Line, key.Value)
+		}
+		value := node.Value
+		if node.Tag == "!!null" {
+			value = ""
+		} else if node.Style != yaml.SingleQuotedStyle {
+			var err error
+			if value, err = d.unquotedValue(value, baseDir); err != nil {
+				return fmt.Errorf("line %d: %v", node.Line, err)
+			}
+		}
+		d.set(client.DictionaryKey(key.Value), value)
+	}
+	return nil
+}
+
+// Returns the value of a dictionary item:
+//   - "..." may contain '=', '#', new lines and the \n, \t, \" and \\ escapes,
+//     and its environment variables are expanded.
+//   - '...' is literal.
+//   - @path/to/file is the content of the file, relative to 'baseDir'.
+//   - Otherwise the environment variables of the value are expanded.
+//
+// The environment variables are only expanded with 'expandEnv'.
+func (d *dataDictionary) value(value, baseDir string) (string, error) {
+	value = strings.TrimSpace(value)
+	if !isQuoted(value) {
+		return d.unquotedValue(value, baseDir)
+	}
+	text, rest, ok := splitQuoted(value)
+	if !ok {
+		return "", fmt.Errorf("missing closing quote")
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected '%s' after the closing quote", rest)
+	}
+	if value[0] == '\'' {
+		return text, nil
+	}
+	return d.expand(text)
+}
+
+// Returns an unquoted value with its environment variables expanded, or the
+// content of the file of an @path/to/file value without its last new line.
+// A value is a file by its own '@', not by the one of a variable.
+func (d *dataDictionary) unquotedValue(value, baseDir string) (string, error) {
+	path, isFile := strings.CutPrefix(value, "@")
+	if !isFile || strings.HasPrefix(path, "@") {
+		return d.expand(value)
+	}
+	path, err := d.expand(path)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(content), "\n"), "\r"), nil
+}
+
+// Expands the '${VAR}' and '${VAR:-default}' references to environment
+// variables, if enabled. A variable without a default must be set, and '$${' is
+// a literal '${'.
+func (d *dataDictionary) expand(value string) (string, error) {
+	if !d.expandEnv {
+		return value, nil
+	}
+	var err error
+	value = envReference.ReplaceAllStringFunc(value, func(reference string) string {
+		if reference == "$${" {
+			return "${"
+		}
+		match := envReference.FindStringSubmatch(reference)
+		if envValue, ok := os.LookupEnv(match[1]); ok {
+			return envValue
+		}
+		if match[2] == "" && err == nil {
+			err = fmt.Errorf("environment variable '%s' not set", match[1])
+		}
+		return match[3]
+	})
+	return value, err
+}
+
+func isQuoted(value string) bool {
+	return strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'")
+}
+
+func quoteClosed(value string) bool {
+	_, _, ok := splitQuoted(value)
+	return ok
+}
+
+// Splits a quoted value into its unescaped text and the rest after the closing
+// quote. Returns false if the closing quote is missing.
+func splitQuoted(value string) (string, string, bool) {
+	quote := value[0]
+	var text strings.Builder
+	for i := 1; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == quote:
+			return text.String(), value[i+1:], true
+		case c == '\\' && quote == '"' && i+1 < len(value):
+			i++
+			switch value[i] {
+			case 'n':
+				text.WriteByte('\n')
+			case 't':
+				text.WriteByte('\t')
+			case 'r':
+				text.WriteByte('\r')
+			default:
+				text.WriteByte(value[i])
+			}
+		default:
+			text.WriteByte(c)
+		}
+	}
+	return "", "", false
+}
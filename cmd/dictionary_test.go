@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDataDictionary(t *testing.T) {
+	t.Setenv("RD_TEST_USER", "admin")
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "cert.pem"), []byte("-----BEGIN-----\nabc\n-----END-----\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "base.dict"), []byte(`# Base values
+@@URL@@=jdbc:mysql://db:3306/app?user=${RD_TEST_USER}&ssl=true
+USER = ${RD_TEST_USER}
+@@TOKEN@@=YWJj==
+@@CERT@@=@cert.pem
+@@HOST@@=${RD_TEST_MISSING:-localhost}
+@@QUOTED@@="a # b\tc" # comment
+@@LITERAL@@='${RD_TEST_USER}=@cert.pem'
+@@MULTI@@="line 1
+line 2"
+@@PORT@@=80
+`), 0644)
+	os.WriteFile(filepath.Join(dir, "prod.yaml"), []byte(`PORT: 443
+"@@HOST@@": prod.example.com
+PASSWORD: '${NOT_EXPANDED}'
+EMPTY:
+`), 0644)
+	os.WriteFile(filepath.Join(dir, "extra.json"), []byte(`{"@@OWNER@@": "${RD_TEST_USER}", "DEBUG": true}`), 0644)
+
+	dictionary := &dataDictionary{expandEnv: true}
+	for _, file := range []string{"base.dict", "prod.yaml", "extra.json"} {
+		if err := dictionary.readFile(filepath.Join(dir, file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dictionary.readItem("@@PORT@@=8443", dir); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"@@URL@@=jdbc:mysql://db:3306/app?user=admin&ssl=true",
+		"@@USER@@=admin",
+		"@@TOKEN@@=YWJj==",
+		"@@CERT@@=-----BEGIN-----\nabc\n-----END-----",
+		"@@HOST@@=prod.example.com",
+		"@@QUOTED@@=a # b\tc",
+		"@@LITERAL@@=${RD_TEST_USER}=@cert.pem",
+		"@@MULTI@@=line 1\nline 2",
+		"@@PORT@@=8443",
+		"@@PASSWORD@@=${NOT_EXPANDED}",
+		"@@EMPTY@@=",
+		"@@OWNER@@=admin",
+		"@@DEBUG@@=true",
+	}
+	if items := dictionary.items(); !reflect.DeepEqual(items, expected) {
+		t.Fatalf("unexpected items:\n%q\nexpected:\n%q", items, expected)
+	}
+
+	for _, content := range []string{"@@A@@=${RD_TEST_MISSING}", `@@A@@="unterminated`, `@@A@@="a" b`, "@@A@@=@missing.txt"} {
+		os.WriteFile(filepath.Join(dir, "bad.dict"), []byte(content), 0644)
+		if err := (&dataDictionary{expandEnv: true}).readFile(filepath.Join(dir, "bad.dict")); err == nil {
+			t.Fatalf("expected an error for %q", content)
+		}
+	}
+
+	// The environment variables are only expanded when enabled, and a file is
+	// only read from a value starting with '@', not from a variable
+	t.Setenv("RD_TEST_FILE", "@cert.pem")
+	dictionary = &dataDictionary{}
+	if err := dictionary.readItem("@@A@@=${RD_TEST_MISSING}", dir); err != nil || dictionary.items()[0] != "@@A@@=${RD_TEST_MISSING}" {
+		t.Fatalf("unexpected item without expansion: %q (%v)", dictionary.items(), err)
+	}
+	dictionary = &dataDictionary{expandEnv: true}
+	if err := dictionary.readItem("@@A@@=${RD_TEST_FILE}", dir); err != nil || dictionary.items()[0] != "@@A@@=@cert.pem" {
+		t.Fatalf("unexpected item from a variable: %q (%v)", dictionary.items(), err)
+	}
+	os.WriteFile(filepath.Join(dir, "bad.yaml"), []byte("A: [1, 2]\n"), 0644)
+	if err := (&dataDictionary{}).readFile(filepath.Join(dir, "bad.yaml")); err == nil {
+		t.Fatal("expected an error for a list value")
+	}
+}
+
+func TestIsDictionaryArg(t *testing.T) {
+	for arg, expected := range map[string]bool{
+		"@@URL@@=jdbc:db?a=b": true,
+		"@@TOKEN@@=YWJj==":    true,
+		"@@EMPTY@@=":          true,
+		"@@=value":            false,
+		"URL=value":           false,
+		"web1.PROD.cfg":       false,
+	} {
+		if isDictionaryArg(arg) != expected {
+			t.Fatalf("expected %v for %q", expected, arg)
+		}
+	}
+}
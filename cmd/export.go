@@ -1,68 +1,244 @@
 // Copyright © 2024 Rafael Ruiz Palacios <support@midvision.com>
 
-// TODO: provide output path as a flag.
-// TODO: create directories of the output flag recursively.
-
 package cmd
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/MidVision/rd/client"
 	"github.com/spf13/cobra"
-	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
-var exportProjectName string
+// Name of the manifest written along with the exported projects
+const exportManifestFile = "manifest.json"
+
+var exportOutputDir string
+var exportAll bool
+var exportParallel int
+
+// The manifest of an export: the exported projects with their checksums, to verify them later.
+type exportManifest struct {
+	Server    string             `json:"server"`
+	CreatedAt time.Time          `json:"createdAt"`
+	Projects  []*exportedProject `json:"projects"`
+}
+
+// A project of an export manifest, or the error exporting it.
+type exportedProject struct {
+	Name string `json:"name"`
+	// The ZIP archive of the project, relative to the manifest
+	File     string `json:"file,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+	Error    string `json:"error,omitempty"`
+	ExitCode int    `json:"-"`
+}
 
 var exportCmd = &cobra.Command{
-	Use:   "export PROJECT_NAME",
-	Short: "Exports a project from RapidDeploy.",
-	Long:  `This command exports a project from RapidDeploy into the current directory.`,
+	Use:   "export [PROJECT_NAME...]",
+	Short: "Exports projects from RapidDeploy.",
+	Long: `This command exports projects from RapidDeploy into ZIP files in the current
+directory, or in the '--output-dir' directory, which is created if needed.
+
+With several project names or the '--all' flag, which exports every project,
+the projects are exported up to '--parallel' at a time and a summary is shown.
+The command exits with the exit code of the worst outcome.
+
+Each run also writes a '` + exportManifestFile + `' file with the URL of the server, the
+date of the export and the size and SHA-256 checksum of each ZIP file, so the
+files can be verified later.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if quiet {
 			os.Stdout = nil
 		}
 		// Check the correct number of arguments
-		if len(args) != 1 {
+		if len(args) == 0 && !exportAll || len(args) != 0 && exportAll {
 			cmd.Usage()
-			os.Exit(1)
-		} else {
-			exportProjectName = args[0]
+			os.Exit(client.ExitUsage)
 		}
 
-		// Load the login session file - initialize the rdClient struct
-		if err := rdClient.loadLoginFile(); err != nil {
-			printStdError("\n%v\n\n", err)
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
+
+		projectNames := args
+		if exportAll {
+			projects, err := rdClient.ListProjects(cmd.Context())
+			checkError(err)
+			projectNames = nil
+			for _, project := range projects {
+				projectNames = append(projectNames, project.Name)
+			}
+			if len(projectNames) == 0 {
+				printStdError("\nNo projects found to export.\n\n")
+				os.Exit(client.ExitNotFound)
+			}
+		}
+		if err := os.MkdirAll(exportOutputDir, 0755); err != nil {
+			printStdError("\nUnable to create directory: %s\n", exportOutputDir)
+			printStdError("%v\n\n", err)
 			os.Exit(1)
 		}
 
-		/*************** Retrieve the project archive ***************/
-		resData, statusCode, _ := rdClient.call(http.MethodGet, "project/"+exportProjectName+"/export", nil, "application/zip", false)
-		if statusCode == 400 {
-			printStdError("\nInvalid project name: %s\n\n", exportProjectName)
-			os.Exit(1)
+		manifest := &exportManifest{Server: rdClient.BaseUrl.String(), CreatedAt: time.Now().UTC()}
+		manifest.Projects = exportProjects(cmd.Context(), projectNames, exportOutputDir)
+		exitCodes := make([]int, len(manifest.Projects))
+		exported := 0
+		for i, project := range manifest.Projects {
+			exitCodes[i] = project.ExitCode
+			if project.Error == "" {
+				exported++
+			}
 		}
-		exportProjectFile := exportProjectName + ".zip"
-		exportProjectAbsPath, err := filepath.Abs(exportProjectFile)
-		if err != nil {
-			printStdError("\n%v\n\n", err)
-			os.Exit(1)
+		if exported > 0 {
+			if err := writeExportManifest(exportOutputDir, manifest); err != nil {
+				printStdError("\n%v\n\n", err)
+				os.Exit(1)
+			}
 		}
-		err = os.WriteFile(exportProjectAbsPath, resData, 0644)
-		if err != nil {
-			printStdError("\nUnable to create file: %s\n", exportProjectAbsPath)
-			printStdError("%v\n\n", err)
-			os.Exit(1)
+
+		if len(args) == 1 {
+			// A single project keeps the output of the previous versions
+			project := manifest.Projects[0]
+			if project.ExitCode == client.ExitNotFound {
+				printStdError("\nInvalid project name: %s\n\n", project.Name)
+				os.Exit(client.ExitNotFound)
+			}
+			if project.Error != "" {
+				printStdError("\n%s\n\n", project.Error)
+				os.Exit(project.ExitCode)
+			}
+			exportProjectAbsPath, _ := filepath.Abs(filepath.Join(exportOutputDir, project.File))
+			fmt.Println()
+			fmt.Println("Project export file: " + exportProjectAbsPath)
+			fmt.Println()
+			return
 		}
 
-		// Show resulting ZIP file
-		fmt.Println()
-		fmt.Println("Project export file: " + exportProjectAbsPath)
-		fmt.Println()
+		// Print data in the selected output format
+		printResult(&result{kind: "ExportList", data: manifest.Projects, list: true,
+			columns: []*column{
+				newColumn("Project", func(project *exportedProject) string { return project.Name }),
+				newColumn("File", func(project *exportedProject) string { return project.File }),
+				newColumn("Size", func(project *exportedProject) string { return sizeText(project) }),
+				wideColumn("SHA-256", func(project *exportedProject) string { return project.SHA256 }),
+				newColumn("Error", func(project *exportedProject) string { return project.Error }),
+			}})
+		if exported > 0 {
+			manifestAbsPath, _ := filepath.Abs(filepath.Join(exportOutputDir, exportManifestFile))
+			fmt.Println("Export manifest file: " + manifestAbsPath)
+			fmt.Println()
+		}
+		os.Exit(client.WorstExitCode(exitCodes...))
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVar(&exportOutputDir, "output-dir", ".", "Directory to write the ZIP files and the manifest into. It is created if it does not exist.")
+	exportCmd.Flags().BoolVar(&exportAll, "all", false, "Exports every project of the server.")
+	exportCmd.Flags().IntVar(&exportParallel, "parallel", 4, "Maximum number of projects exported at a time.")
+}
+
+// Exports projects into ZIP files in a directory, up to '--parallel' at a
+// time, and returns the outcome of each export.
+func exportProjects(ctx context.Context, projectNames []string, outputDir string) []*exportedProject {
+	projects := make([]*exportedProject, len(projectNames))
+	sem := make(chan struct{}, max(exportParallel, 1))
+	var wg sync.WaitGroup
+	for i, name := range projectNames {
+		project := &exportedProject{Name: name}
+		projects[i] = project
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := exportProject(ctx, project, outputDir); err != nil {
+				project.ExitCode = exitCode(err)
+				project.Error = strings.ReplaceAll(err.Error(), "\n", " ")
+			}
+		}()
+	}
+	wg.Wait()
+	return projects
+}
+
+// Exports a project into a ZIP file in a directory, recording its size and checksum.
+func exportProject(ctx context.Context, project *exportedProject, outputDir string) error {
+	logger.Debug("Exporting project", "project", project.Name)
+	resData, err := exportArchive(ctx, rdClient, project.Name)
+	if err != nil {
+		return err
+	}
+	file := fileName(project.Name) + ".zip"
+	path := filepath.Join(outputDir, file)
+	if err := os.WriteFile(path, resData, 0644); err != nil {
+		return fmt.Errorf("Unable to create file: %s: %v", path, err)
+	}
+	checksum := sha256.Sum256(resData)
+	project.File, project.Size, project.SHA256 = file, int64(len(resData)), hex.EncodeToString(checksum[:])
+	return nil
+}
+
+// Returns a file name for a name given by the server, e.g. a project name,
+// escaping the path separators so the file stays in its directory.
+func fileName(name string) string {
+	return fileNameEscaper.Replace(name)
+}
+
+var fileNameEscaper = strings.NewReplacer("%", "%25", "/", "%2F", "\\", "%5C")
+
+// Returns the ZIP archive of a project of a server.
+func exportArchive(ctx context.Context, rdc *client.RDClient, projectName string) ([]byte, error) {
+	resData, err := rdc.Export(ctx, projectName)
+	var validationErr *client.ValidationError
+	if errors.As(err, &validationErr) {
+		// The server rejects the export of a project which does not exist
+		return nil, &client.NotFoundError{ResponseError: validationErr.ResponseError}
+	}
+	return resData, err
+}
+
+// Writes the manifest of an export into a directory.
+func writeExportManifest(outputDir string, manifest *exportManifest) error {
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(outputDir, exportManifestFile)
+	if err := os.WriteFile(path, append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("Unable to create file: %s: %v", path, err)
+	}
+	return nil
+}
+
+// Reads the manifest of an export.
+func readExportManifest(path string) (*exportManifest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &exportManifest{}
+	if err := json.Unmarshal(content, manifest); err != nil {
+		return nil, fmt.Errorf("Invalid export manifest: %s: %v", path, err)
+	}
+	return manifest, nil
+}
+
+// Returns the size of an exported project in bytes, or nothing if it failed.
+func sizeText(project *exportedProject) string {
+	if project.Error != "" {
+		return ""
+	}
+	return strconv.FormatInt(project.Size, 10)
 }
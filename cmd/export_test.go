@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/MidVision/rd/client"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExportProjects(t *testing.T) {
+	// The exports share the client, run them in parallel to check it with the race detector
+	var mu sync.Mutex
+	running, peak := 0, 0
+	newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		defer func() { mu.Lock(); running--; mu.Unlock() }()
+		time.Sleep(50 * time.Millisecond)
+		switch r.URL.Path {
+		case "/ws/project/app/export", "/ws/project/db/export", "/ws/web/export":
+			w.Write([]byte("zip of " + r.URL.Path))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	dir := filepath.Join(t.TempDir(), "backups", "today")
+	os.MkdirAll(dir, 0755)
+	defer func(n int) { exportParallel = n }(exportParallel)
+	exportParallel = 2
+	projects := exportProjects(context.Background(), []string{"app", "missing", "db"}, dir)
+	if peak != 2 {
+		t.Fatalf("expected 2 exports at a time, got %v", peak)
+	}
+	if projects[1].ExitCode != client.ExitNotFound || projects[1].File != "" {
+		t.Fatalf("expected the missing project to fail: %+v", projects[1])
+	}
+	checksum := sha256.Sum256([]byte("zip of /ws/project/db/export"))
+	if projects[2].File != "db.zip" || projects[2].SHA256 != hex.EncodeToString(checksum[:]) || projects[2].Size != int64(len("zip of /ws/project/db/export")) {
+		t.Fatalf("unexpected export: %+v", projects[2])
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "db.zip")); string(content) != "zip of /ws/project/db/export" {
+		t.Fatalf("unexpected file content: %s", content)
+	}
+
+	// A project name with path separators is written in the directory anyway
+	if escaped := exportProjects(context.Background(), []string{"../web"}, dir); escaped[0].File != "..%2Fweb.zip" {
+		t.Fatalf("unexpected export: %+v", escaped[0])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "..%2Fweb.zip")); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := &exportManifest{Server: rdClient.BaseUrl.String(), CreatedAt: time.Now().UTC(), Projects: projects}
+	if err := writeExportManifest(dir, manifest); err != nil {
+		t.Fatal(err)
+	}
+	var written exportManifest
+	content, _ := os.ReadFile(filepath.Join(dir, exportManifestFile))
+	if err := json.Unmarshal(content, &written); err != nil {
+		t.Fatal(err)
+	}
+	if written.Server != rdClient.BaseUrl.String() || len(written.Projects) != 3 || written.Projects[0].SHA256 != projects[0].SHA256 || written.Projects[1].Error == "" {
+		t.Fatalf("unexpected manifest: %s", content)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	errorColor = "\033[31m"
+	warnColor  = "\033[33m"
+	resetColor = "\033[0m"
+)
+
+// Prints the new lines of the log of a running job on each poll, like 'tail -f'.
+// The server always returns the whole log, so the part already printed is skipped.
+type logFollower struct {
+	out io.Writer
+	// Highlights the ERROR and WARN lines
+	highlight bool
+
+	// The last log retrieved and the number of bytes of it already printed
+	log    []byte
+	offset int
+}
+
+// Retrieves the log and prints its new complete lines. A log not available
+// yet, e.g. because the job has not started, is not an error.
+func (f *logFollower) poll(ctx context.Context, jobId string) {
+	resData, err := rdClient.GetJobLog(ctx, jobId)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			checkError(err)
+		}
+		logger.Debug("Unable to retrieve the job log", "job", jobId, "error", err)
+		return
+	}
+	if len(resData) < f.offset {
+		// The log was replaced, print it again
+		f.offset = 0
+	}
+	f.log = resData
+	if end := bytes.LastIndexByte(f.log[f.offset:], '\n'); end >= 0 {
+		f.printLines(f.log[f.offset : f.offset+end+1])
+		f.offset += end + 1
+	}
+}
+
+// Prints the last line of the log if it does not end with a new line.
+func (f *logFollower) flush() {
+	if f.offset < len(f.log) {
+		f.printLines(append(f.log[f.offset:len(f.log):len(f.log)], '\n'))
+		f.offset = len(f.log)
+	}
+}
+
+func (f *logFollower) printLines(content []byte) {
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		if line != "" {
+			fmt.Fprint(f.out, highlightLine(line, f.highlight))
+		}
+	}
+}
+
+// Colors a line of a log containing ERROR in red and one containing WARN in yellow.
+func highlightLine(line string, highlight bool) string {
+	if !highlight {
+		return line
+	}
+	text, newLine := strings.CutSuffix(line, "\n")
+	color := ""
+	switch {
+	case strings.Contains(text, "ERROR"):
+		color = errorColor
+	case strings.Contains(text, "WARN"):
+		color = warnColor
+	default:
+		return line
+	}
+	line = color + text + resetColor
+	if newLine {
+		line += "\n"
+	}
+	return line
+}
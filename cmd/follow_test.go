@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLogFollower(t *testing.T) {
+	logs := []string{"", "line 1\nERROR line", "line 1\nERROR line 2\nline 3"}
+	poll := 0
+	newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if logs[poll] == "" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte(logs[poll]))
+	})
+
+	var out strings.Builder
+	follower := &logFollower{out: &out, highlight: true}
+	for poll = range logs {
+		follower.poll(context.Background(), "42")
+	}
+	follower.flush()
+	expected := "line 1\n" + errorColor + "ERROR line 2" + resetColor + "\nline 3\n"
+	if out.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, out.String())
+	}
+}
@@ -3,71 +3,394 @@
 package cmd
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"github.com/MidVision/rd/client"
 	"github.com/spf13/cobra"
-	"io/ioutil"
-	"net/http"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-var importProjectPath string
+// Statuses of the files of an import
+const (
+	importValid    = "VALID"
+	importInvalid  = "INVALID"
+	importImported = "IMPORTED"
+	importFailed   = "FAILED"
+)
+
+var importDryRun bool
+
+// A file to import and the outcome of its import.
+type importedFile struct {
+	File    string `json:"file"`
+	Project string `json:"project,omitempty"`
+	// Whether the project already exists in the server
+	Exists   bool   `json:"exists,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	ExitCode int    `json:"exitCode"`
+
+	// The size and checksum of the file in an export manifest, if any
+	size   int64
+	sha256 string
+	err    error
+}
 
 var importCmd = &cobra.Command{
-	Use:   "import PROJECT_FILE_PATH",
-	Short: "Imports a project into RapidDeploy.",
-	Long:  `This command imports a project into RapidDeploy. You need to provide the absolute, or relative to current directory, path to the project ZIP file.`,
+	Use:   "import PROJECT_FILE_PATH...",
+	Short: "Imports projects into RapidDeploy.",
+	Long: `This command imports projects into RapidDeploy. You need to provide the absolute,
+or relative to current directory, path to the project ZIP files, to directories
+whose ZIP files are imported, or to the '` + exportManifestFile + `' files written by the
+'export' command, whose projects are imported.
+
+Before uploading anything, each file is checked: it must be a valid ZIP file
+containing a project descriptor, an XML file whose root element is <Project>,
+with the name of the project. The size and SHA-256 checksum of the files listed
+in an export manifest, also the one of a directory, must match it. A warning is
+shown for the projects which already exist in the server, as importing them
+replaces them.
+
+With the '--dry-run' flag the files are only checked. Otherwise the valid files
+are imported one after the other and a summary is shown. The command exits
+with the exit code of the worst outcome.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if quiet {
 			os.Stdout = nil
 		}
 		// Check the correct number of arguments
-		if len(args) != 1 {
+		if len(args) == 0 {
 			cmd.Usage()
-			os.Exit(1)
-		} else {
-			importProjectPath = args[0]
+			os.Exit(client.ExitUsage)
 		}
+		files := importFiles(args)
 
-		// Load the login session file - initialize the rdClient struct
-		if err := rdClient.loadLoginFile(); err != nil {
-			printStdError("\n%v\n\n", err)
-			os.Exit(1)
-		}
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
 
-		// Prepare the body of the request
-		fileArray, err := ioutil.ReadFile(importProjectPath)
-		if err != nil {
-			printStdError("\n%v\n\n", err)
-			os.Exit(1)
+		projects, err := rdClient.ListProjects(cmd.Context())
+		checkError(err)
+		for _, file := range files {
+			if file.Status == "" {
+				checkImportFile(file, projects)
+			}
 		}
-
-		if debug {
-			fmt.Printf("[DEBUG] Importing project file: %v\n", importProjectPath)
+		if !importDryRun {
+			for _, file := range files {
+				if file.Status == importValid {
+					importFile(cmd.Context(), file)
+				}
+			}
 		}
 
-		/*************** Import the project archive ***************/
-		resData, statusCode, _ := rdClient.call(http.MethodPut, "project/import", fileArray, "application/zip", false)
-		if statusCode == 200 {
-			fmt.Println()
-			fmt.Println("File '" + importProjectPath + "' imported successfuly.")
-			fmt.Println()
-		} else if statusCode == 400 {
-			htmlResponse := new(Html)
-			err = xml.Unmarshal(resData, &htmlResponse)
-			if err != nil {
-				printStdError("\n%v\n\n", err)
-				os.Exit(1)
+		if len(files) == 1 && !importDryRun && !machineOutput() {
+			// A single file keeps the output of the previous versions
+			file := files[0]
+			if file.Status == importFailed {
+				checkError(file.err)
 			}
-			if len(htmlResponse.Body.Div[1].Div[0].Ul.Li) != 0 {
-				for _, message := range htmlResponse.Body.Div[1].Div[0].Ul.Li {
-					printStdError("\n%v\n\n", message.Span[1])
-				}
+			if file.Status == importInvalid {
+				printStdError("\n%s: %s\n\n", file.File, file.Error)
+				os.Exit(file.ExitCode)
 			}
+			fmt.Println()
+			fmt.Println("File '" + file.File + "' imported successfuly.")
+			fmt.Println()
+			return
+		}
+
+		// Print data in the selected output format
+		printResult(&result{kind: "ImportList", data: files, list: true,
+			columns: []*column{
+				newColumn("File", func(file *importedFile) string { return file.File }),
+				newColumn("Project", func(file *importedFile) string { return file.Project }),
+				newColumn("Exists?", func(file *importedFile) string { return strconv.FormatBool(file.Exists) }),
+				newColumn("Status", func(file *importedFile) string { return file.Status }),
+				newColumn("Error", func(file *importedFile) string { return file.Error }),
+			}})
+		exitCodes := make([]int, len(files))
+		for i, file := range files {
+			exitCodes[i] = file.ExitCode
 		}
+		os.Exit(client.WorstExitCode(exitCodes...))
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(importCmd)
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Checks the files without importing them.")
+}
+
+// Returns the files to import for the arguments: ZIP files, directories with
+// ZIP files and export manifests. A path which cannot be read is returned as
+// an invalid file.
+func importFiles(args []string) []*importedFile {
+	var files []*importedFile
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		switch {
+		case err != nil:
+			files = append(files, invalidFile(arg, err, client.ExitNotFound))
+		case info.IsDir():
+			manifestFiles := map[string]*importedFile{}
+			if manifest, err := readExportManifest(filepath.Join(arg, exportManifestFile)); err == nil {
+				for _, file := range manifestImportFiles(arg, manifest) {
+					manifestFiles[file.File] = file
+				}
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				files = append(files, invalidFile(filepath.Join(arg, exportManifestFile), err, client.ExitError))
+			}
+			paths, _ := filepath.Glob(filepath.Join(arg, "*.zip"))
+			sort.Strings(paths)
+			for _, path := range paths {
+				if file, ok := manifestFiles[path]; ok {
+					files = append(files, file)
+				} else {
+					files = append(files, &importedFile{File: path})
+				}
+			}
+			if len(paths) == 0 {
+				files = append(files, invalidFile(arg, errors.New("No ZIP files found in the directory"), client.ExitNotFound))
+			}
+		case strings.EqualFold(filepath.Ext(arg), ".json"):
+			manifest, err := readExportManifest(arg)
+			if err != nil {
+				files = append(files, invalidFile(arg, err, client.ExitError))
+				continue
+			}
+			files = append(files, manifestImportFiles(filepath.Dir(arg), manifest)...)
+		default:
+			files = append(files, &importedFile{File: arg})
+		}
+	}
+	return files
+}
+
+// Returns the files of the projects exported successfully in a manifest of a directory.
+func manifestImportFiles(dir string, manifest *exportManifest) []*importedFile {
+	var files []*importedFile
+	for _, project := range manifest.Projects {
+		if project.Error == "" {
+			files = append(files, &importedFile{File: filepath.Join(dir, project.File), size: project.Size, sha256: project.SHA256})
+		}
+	}
+	return files
+}
+
+func invalidFile(path string, err error, exitCode int) *importedFile {
+	file := &importedFile{File: path}
+	file.fail(importInvalid, err, exitCode)
+	return file
+}
+
+func (file *importedFile) fail(status string, err error, exitCode int) {
+	file.Status, file.err, file.ExitCode = status, err, exitCode
+	file.Error = strings.ReplaceAll(err.Error(), "\n", " ")
+}
+
+// Checks a file before importing it: its checksum, if it is in a manifest,
+// and its project descriptor. Warns if its project exists in the server.
+func checkImportFile(file *importedFile, projects []*client.Project) {
+	content, err := os.ReadFile(file.File)
+	if err != nil {
+		file.fail(importInvalid, err, client.ExitNotFound)
+		return
+	}
+	if file.sha256 != "" {
+		checksum := sha256.Sum256(content)
+		if int64(len(content)) != file.size || hex.EncodeToString(checksum[:]) != file.sha256 {
+			file.fail(importInvalid, errors.New("The size or the SHA-256 checksum of the file does not match the export manifest"), client.ExitError)
+			return
+		}
+	}
+	if file.Project, err = projectArchiveName(content); err != nil {
+		file.fail(importInvalid, err, client.ExitError)
+		return
+	}
+	for _, project := range projects {
+		if project.Name == file.Project {
+			file.Exists = true
+			printStdError("WARNING: Project '%s' of file '%s' already exists in the server and will be replaced.\n", file.Project, file.File)
+		}
+	}
+	file.Status = importValid
+}
+
+// Imports a checked file, recording the outcome.
+func importFile(ctx context.Context, file *importedFile) {
+	content, err := os.ReadFile(file.File)
+	if err != nil {
+		file.fail(importFailed, err, client.ExitError)
+		return
+	}
+	logger.Debug("Importing project file", "path", file.File, "project", file.Project)
+	if !machineOutput() {
+		fmt.Printf("Importing project '%s' from '%s'...\n", file.Project, file.File)
+	}
+	if err := rdClient.Import(ctx, content); err != nil {
+		file.fail(importFailed, err, exitCode(err))
+		return
+	}
+	file.Status = importImported
+}
+
+// Returns the name of the project of a project archive, read from its
+// descriptor: an XML file whose root element is <Project>. Every file of the
+// archive is read to check it is not corrupted.
+func projectArchiveName(content []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", fmt.Errorf("Invalid ZIP file: %v", err)
+	}
+	projectName := ""
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		reader, err := entry.Open()
+		if err != nil {
+			return "", fmt.Errorf("Invalid ZIP file: %s: %v", entry.Name, err)
+		}
+		entryContent, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return "", fmt.Errorf("Invalid ZIP file: %s: %v", entry.Name, err)
+		}
+		if projectName == "" && strings.EqualFold(filepath.Ext(entry.Name), ".xml") {
+			if projectName, err = projectDescriptorName(entryContent); err != nil {
+				return "", fmt.Errorf("Invalid project descriptor '%s': %v", entry.Name, err)
+			}
+		}
+	}
+	if projectName == "" {
+		return "", errors.New("No project descriptor found in the ZIP file")
+	}
+	return projectName, nil
+}
+
+// Returns the name of the project of a project descriptor, or an empty
+// string if the XML document is not a project descriptor.
+func projectDescriptorName(content []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			// Not an XML document
+			return "", nil
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != "Project" {
+			return "", nil
+		}
+		var project client.Project
+		if err := decoder.DecodeElement(&project, &start); err != nil {
+			return "", err
+		}
+		if project.Name == "" {
+			return "", errors.New("the project has no name")
+		}
+		return project.Name, nil
+	}
+}
+
+// Returns a copy of a project archive with the project renamed in its descriptor.
+func renameProjectArchive(content []byte, name string) ([]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid ZIP file: %v", err)
+	}
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	renamed := false
+	for _, entry := range archive.File {
+		if renamed || entry.FileInfo().IsDir() || !strings.EqualFold(filepath.Ext(entry.Name), ".xml") {
+			if err := writer.Copy(entry); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		reader, err := entry.Open()
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ZIP file: %s: %v", entry.Name, err)
+		}
+		entryContent, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ZIP file: %s: %v", entry.Name, err)
+		}
+		if projectName, _ := projectDescriptorName(entryContent); projectName != "" {
+			if entryContent, err = renameProjectDescriptor(entryContent, name); err != nil {
+				return nil, fmt.Errorf("Invalid project descriptor '%s': %v", entry.Name, err)
+			}
+			renamed = true
+		}
+		header := entry.FileHeader
+		file, err := writer.CreateHeader(&header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Write(entryContent); err != nil {
+			return nil, err
+		}
+	}
+	if !renamed {
+		return nil, errors.New("No project descriptor found in the ZIP file")
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Replaces the name of the project of a project descriptor, keeping the rest of the document as it is.
+func renameProjectDescriptor(content []byte, name string) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, errors.New("the project has no name")
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth != 2 || token.Name.Local != "name" {
+				continue
+			}
+			// Find the end of the text of the element
+			start, end := decoder.InputOffset(), decoder.InputOffset()
+			for {
+				token, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				if _, ok := token.(xml.EndElement); ok {
+					break
+				}
+				end = decoder.InputOffset()
+			}
+			var renamed bytes.Buffer
+			renamed.Write(content[:start])
+			xml.EscapeText(&renamed, []byte(name))
+			renamed.Write(content[end:])
+			return renamed.Bytes(), nil
+		case xml.EndElement:
+			depth--
+		}
+	}
 }
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/MidVision/rd/client"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Returns a ZIP archive with the given files and contents.
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for name, content := range files {
+		file, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write([]byte(content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProjectArchiveName(t *testing.T) {
+	tests := []struct {
+		files   map[string]string
+		name    string
+		wantErr bool
+	}{
+		{files: map[string]string{"app/app.xml": "<?xml version=\"1.0\"?><Project><name>app</name><enabled>true</enabled></Project>", "app/deploy.xml": "<deploy/>"}, name: "app"},
+		{files: map[string]string{"readme.txt": "not a project"}, wantErr: true},
+		{files: map[string]string{"app.xml": "<Project><description>no name</description></Project>"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		name, err := projectArchiveName(zipArchive(t, tt.files))
+		if (err != nil) != tt.wantErr || name != tt.name {
+			t.Errorf("projectArchiveName(%v) = %q, %v", tt.files, name, err)
+		}
+	}
+	if _, err := projectArchiveName([]byte("not a zip")); err == nil {
+		t.Error("expected an error for a file which is not a ZIP file")
+	}
+}
+
+func TestImportFiles(t *testing.T) {
+	var imported []string
+	newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		imported = append(imported, r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	dir := t.TempDir()
+	app := zipArchive(t, map[string]string{"app.xml": "<Project><name>app</name></Project>"})
+	db := zipArchive(t, map[string]string{"db.xml": "<Project><name>db</name></Project>"})
+	os.WriteFile(filepath.Join(dir, "app.zip"), app, 0644)
+	os.WriteFile(filepath.Join(dir, "db.zip"), db, 0644)
+	os.WriteFile(filepath.Join(dir, "broken.zip"), []byte("broken"), 0644)
+	checksum := sha256.Sum256(app)
+	manifest := &exportManifest{Projects: []*exportedProject{
+		{Name: "app", File: "app.zip", Size: int64(len(app)), SHA256: hex.EncodeToString(checksum[:])},
+		{Name: "db", File: "db.zip", Size: int64(len(db)), SHA256: hex.EncodeToString(checksum[:])},
+		{Name: "web", Error: "Not found"},
+	}}
+	if err := writeExportManifest(dir, manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	files := importFiles([]string{filepath.Join(dir, exportManifestFile), dir, filepath.Join(dir, "missing.zip")})
+	if len(files) != 6 || files[5].ExitCode != client.ExitNotFound {
+		t.Fatalf("unexpected files: %+v", files)
+	}
+	for _, file := range files {
+		if file.Status == "" {
+			checkImportFile(file, []*client.Project{{Name: "app"}})
+		}
+	}
+	// The manifest, then the directory sorted by name
+	statuses := []string{importValid, importInvalid, importValid, importInvalid, importInvalid, importInvalid}
+	for i, file := range files {
+		if file.Status != statuses[i] {
+			t.Errorf("unexpected status of file %d: %+v", i, file)
+		}
+	}
+	if !files[0].Exists || files[0].Project != "app" {
+		t.Errorf("expected the existing project to be detected: %+v", files[0])
+	}
+
+	importFile(context.Background(), files[0])
+	if files[0].Status != importImported || len(imported) != 1 || imported[0] != "/ws/project/import" {
+		t.Fatalf("unexpected import: %+v %v", files[0], imported)
+	}
+}
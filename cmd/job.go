@@ -0,0 +1,435 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/MidVision/rd/client"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts of the dates accepted by the '--since' flag and returned by the server.
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05.000Z0700", "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+var jobLogFile, jobProject, jobSince string
+var jobStatuses []string
+var jobScheduled bool
+
+// jobCmd represents the job command
+var jobCmd = &cobra.Command{
+	Use:   "job",
+	Short: "Manages the jobs started in RapidDeploy.",
+	Long: `Manages the jobs started in RapidDeploy, e.g. by the 'deploy' or
+'startJobPlan' commands, using the job ID they print.`,
+}
+
+var jobStatusCmd = &cobra.Command{
+	Use:   "status JOB_ID",
+	Short: "Shows the details of a job.",
+	Long:  `Shows the details of a job, including its status.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if quiet {
+			os.Stdout = nil
+		}
+		if len(args) != 1 {
+			cmd.Usage()
+			os.Exit(client.ExitUsage)
+		}
+
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
+
+		jobDetails, err := rdClient.GetJob(cmd.Context(), args[0])
+		checkError(err)
+		printResult(jobResult(jobDetails))
+	},
+}
+
+var jobLogCmd = &cobra.Command{
+	Use:   "log JOB_ID",
+	Short: "Retrieves the log of a job.",
+	Long:  `Retrieves the log of a job and prints it, or saves it into a file with the '--output' flag.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if quiet {
+			os.Stdout = nil
+		}
+		if len(args) != 1 {
+			cmd.Usage()
+			os.Exit(client.ExitUsage)
+		}
+
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
+
+		if jobLogFile != "" {
+			checkError(saveJobLog(cmd.Context(), args[0], jobLogFile))
+			return
+		}
+		resData, err := rdClient.GetJobLog(cmd.Context(), args[0])
+		checkError(err)
+		documentOutput.Write(resData)
+	},
+}
+
+var jobCancelCmd = &cobra.Command{
+	Use:   "cancel JOB_ID",
+	Short: "Cancels a job.",
+	Long:  `Cancels a job which has not finished yet, e.g. a scheduled one, and shows its details.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if quiet {
+			os.Stdout = nil
+		}
+		if len(args) != 1 {
+			cmd.Usage()
+			os.Exit(client.ExitUsage)
+		}
+
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
+
+		jobDetails, err := rdClient.CancelJob(cmd.Context(), args[0])
+		checkError(err)
+		fmt.Printf("\nJob '%s' cancelled.\n", args[0])
+		printResult(jobResult(jobDetails))
+	},
+}
+
+var jobRescheduleCmd = &cobra.Command{
+	Use:   "reschedule JOB_ID (--at DATE | --in PERIOD)",
+	Short: "Changes the date a scheduled job starts at.",
+	Long: `Changes the date a scheduled job starts at and shows its details.
+The dates without a time zone are in local time.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if quiet {
+			os.Stdout = nil
+		}
+		if len(args) != 1 || scheduleAt == "" && scheduleIn == "" {
+			cmd.Usage()
+			os.Exit(client.ExitUsage)
+		}
+		scheduledDate, err := parseSchedule(scheduleAt, scheduleIn, time.Now())
+		if err != nil {
+			printStdError("\n%v\n\n", err)
+			os.Exit(client.ExitUsage)
+		}
+
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
+
+		jobDetails, err := rdClient.RescheduleJob(cmd.Context(), args[0], scheduledDate)
+		checkError(err)
+		fmt.Printf("\nJob '%s' rescheduled for %s\n", args[0], scheduledDate.Local().Format(scheduleDisplayLayout))
+		printResult(jobResult(jobDetails))
+	},
+}
+
+var jobWaitCmd = &cobra.Command{
+	Use:   "wait JOB_ID...",
+	Short: "Waits for one or more jobs to finish.",
+	Long: `Waits for one or more jobs to finish and shows their final status.
+
+If any of the jobs does not finish successfully, it exits with the exit
+code for the status of the first one, e.g. 10 for FAILED, or 14 (awaiting
+approval) if it waits for an approval for longer than the '--approval-timeout'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if quiet {
+			os.Stdout = nil
+		}
+		if len(args) < 1 {
+			cmd.Usage()
+			os.Exit(client.ExitUsage)
+		}
+
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
+
+		var summaries []*client.JobSummary
+		exitCode := client.ExitOK
+		for _, jobId := range args {
+			fmt.Printf("Waiting for job '%s'...\n", jobId)
+			jobDetails, err := waitForJob(cmd.Context(), jobId, "Job", nil, approvalTimeout)
+			checkError(err)
+			summary := jobDetails.Summary()
+			if summary.JobId == "" {
+				summary.JobId = jobId
+			}
+			summaries = append(summaries, summary)
+			if exitCode == client.ExitOK {
+				exitCode = client.StatusExitCode(summary.Status)
+			}
+		}
+
+		printResult(&result{kind: "JobList", data: summaries, list: true,
+			columns: []*column{
+				newColumn("Job ID", func(summary *client.JobSummary) string { return summary.JobId }),
+				newColumn("Status", func(summary *client.JobSummary) string { return summary.Status }),
+			}})
+		os.Exit(exitCode)
+	},
+}
+
+var jobListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the jobs started in RapidDeploy.",
+	Long: `Lists the jobs started in RapidDeploy, most recent first.
+
+With the '--scheduled' flag it lists the upcoming scheduled jobs instead,
+the first one to start first, with their scheduled dates in local time.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if quiet {
+			os.Stdout = nil
+		}
+		since, err := parseSince(jobSince, time.Now())
+		if err != nil {
+			printStdError("\n%v\n\n", err)
+			os.Exit(client.ExitUsage)
+		}
+
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
+
+		// Perform the REST call to get the data
+		rdJobs, err := rdClient.ListJobs(cmd.Context(), jobProject)
+		checkError(err)
+
+		var jobs []*client.Job
+		for _, job := range rdJobs {
+			if matchesJob(job, jobStatuses, since) && (!jobScheduled || isScheduled(job)) {
+				jobs = append(jobs, job)
+			}
+		}
+
+		// Print data in the selected output format
+		if jobScheduled {
+			sort.SliceStable(jobs, func(i, j int) bool {
+				return scheduledBefore(jobs[i], jobs[j])
+			})
+			printResult(&result{kind: "JobList", data: jobs, list: true, empty: "No scheduled jobs available to show",
+				columns: []*column{
+					newColumn("ID", func(job *client.Job) string { return strconv.Itoa(job.Id) }),
+					newColumn("Project", func(job *client.Job) string { return job.ProjectName }),
+					newColumn("Target", func(job *client.Job) string { return job.Target }),
+					wideColumn("Package", func(job *client.Job) string { return job.PackageName }),
+					newColumn("Status", func(job *client.Job) string { return job.Status }),
+					wideColumn("Requested by", func(job *client.Job) string { return job.RequestedBy }),
+					newColumn("Scheduled date", func(job *client.Job) string { return localDate(job.ScheduledDate) }),
+				}})
+			return
+		}
+		printResult(&result{kind: "JobList", data: jobs, list: true, empty: "No jobs available to show",
+			columns: []*column{
+				newColumn("ID", func(job *client.Job) string { return strconv.Itoa(job.Id) }),
+				newColumn("Project", func(job *client.Job) string { return job.ProjectName }),
+				newColumn("Target", func(job *client.Job) string { return job.Target }),
+				wideColumn("Package", func(job *client.Job) string { return job.PackageName }),
+				newColumn("Status", func(job *client.Job) string { return job.Status }),
+				wideColumn("Requested by", func(job *client.Job) string { return job.RequestedBy }),
+				newColumn("Start date", func(job *client.Job) string { return job.StartDate }),
+				wideColumn("End date", func(job *client.Job) string { return job.EndDate }),
+			}})
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(jobCmd)
+	jobCmd.AddCommand(jobStatusCmd)
+	jobCmd.AddCommand(jobLogCmd)
+	jobCmd.AddCommand(jobCancelCmd)
+	jobCmd.AddCommand(jobRescheduleCmd)
+	jobCmd.AddCommand(jobWaitCmd)
+	jobCmd.AddCommand(jobListCmd)
+	jobLogCmd.Flags().StringVarP(&jobLogFile, "output", "o", "", "Saves the log into this file instead of printing it.")
+	jobWaitCmd.Flags().DurationVar(&approvalTimeout, "approval-timeout", 0, "Maximum duration to wait for each job to be approved, 0 means no limit.")
+	jobListCmd.Flags().StringVar(&jobProject, "project", "", "Lists only the jobs of this project.")
+	jobListCmd.Flags().StringSliceVar(&jobStatuses, "status", nil, "Lists only the jobs with one of these comma-separated statuses, e.g. 'FAILED,CANCELLED'.")
+	jobListCmd.Flags().StringVar(&jobSince, "since", "", "Lists only the jobs started after a date (e.g. '2017-06-30') or in the last period of time (e.g. '2h' or '7d').")
+	jobListCmd.Flags().BoolVar(&jobScheduled, "scheduled", false, "Lists only the upcoming scheduled jobs, with their scheduled dates in local time.")
+	addListFlags(jobListCmd)
+	addScheduleFlags(jobRescheduleCmd, "Starts the job")
+}
+
+// Polls a job until it finishes and returns its final details. The progress
+// messages refer to the job as 'kind', e.g. 'Deployment'. If 'follower' is
+// not nil, the new lines of the log are printed on each poll and the
+// progress messages only when the status changes. If 'approvalTimeout' is
+// not 0, it gives up when the job has been waiting for an approval for
+// longer, returning its details in the REQUESTED state.
+func waitForJob(ctx context.Context, jobId, kind string, follower *logFollower, approvalTimeout time.Duration) (client.JobDetails, error) {
+	timeToSleep := 0 * time.Second
+	lastStatus := ""
+	var requestedSince time.Time
+	for {
+		select {
+		case <-time.After(timeToSleep):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		jobDetails, err := rdClient.GetJob(ctx, jobId)
+		if err != nil {
+			return nil, err
+		}
+		jobStatus := jobDetails.Status()
+		if jobStatus == "" {
+			return nil, fmt.Errorf("Unable to read the status of job %s from the server response", jobId)
+		}
+		if follower != nil {
+			follower.poll(ctx, jobId)
+		}
+
+		awaitingApproval := client.StatusExitCode(jobStatus) == client.ExitAwaitingApproval
+		if awaitingApproval {
+			if requestedSince.IsZero() {
+				requestedSince = time.Now()
+			}
+			timeToSleep = 30 * time.Second
+			if approvalTimeout > 0 {
+				remaining := approvalTimeout - time.Since(requestedSince)
+				if remaining <= 0 {
+					fmt.Printf("  No approval received in %v, giving up waiting.\n", approvalTimeout)
+					return jobDetails, nil
+				}
+				timeToSleep = min(timeToSleep, remaining)
+			}
+		} else {
+			requestedSince = time.Time{}
+		}
+
+		if follower != nil && jobStatus == lastStatus {
+			continue
+		}
+		lastStatus = jobStatus
+		if follower != nil && client.IsFinished(jobStatus) {
+			follower.flush()
+		}
+		fmt.Printf("> %s status: %s\n", kind, jobStatus)
+		switch {
+		case client.IsRunning(jobStatus):
+			fmt.Printf("  %s running, next check in 5 seconds...\n", kind)
+			timeToSleep = 5 * time.Second
+		case awaitingApproval:
+			fmt.Printf("  %s in a %s state. Approval is required to continue with the execution, e.g. with 'rd approval approve %s', next check in %v...\n",
+				kind, jobStatus, jobId, timeToSleep.Round(time.Second))
+		case jobStatus == client.StatusScheduled:
+			fmt.Printf("  %s in a SCHEDULED state, the execution will start in a future date, next check in 5 minutes...\n", kind)
+			fmt.Printf("  > Printing out %s details: \n", strings.ToLower(kind))
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			table.SetAutoMergeCells(true)
+			for _, message := range jobDetails {
+				table.Append([]string{message.Title(), message.Value()})
+			}
+			table.Render()
+			timeToSleep = 300 * time.Second
+		default:
+			fmt.Printf("%s finished with status: %s\n", kind, jobStatus)
+			return jobDetails, nil
+		}
+	}
+}
+
+// Returns the path to save the log of a job into: 'logPath' itself or, if
+// it is a directory, the original name of the log file inside it.
+func jobLogPath(logPath, jobId string, jobDetails client.JobDetails) string {
+	if info, err := os.Stat(logPath); err != nil || !info.IsDir() {
+		return logPath
+	}
+	logFilename := jobDetails.LogFilename()
+	if logFilename == "" {
+		logFilename = "job-" + jobId + ".log"
+	}
+	return filepath.Join(logPath, logFilename)
+}
+
+// Retrieves the log of a job and saves it into a file.
+func saveJobLog(ctx context.Context, jobId, logFilename string) error {
+	logFilePath, err := filepath.Abs(logFilename)
+	if err != nil {
+		return err
+	}
+	resData, err := rdClient.GetJobLog(ctx, jobId)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(logFilePath, resData, 0644); err != nil {
+		return fmt.Errorf("Unable to create file: %s\n%v", logFilePath, err)
+	}
+	fmt.Printf("Log file available at '%s'\n", logFilePath)
+	return nil
+}
+
+// Parses the value of the '--since' flag: a date or a period of time before
+// 'now', in days (e.g. '7d') or as a duration (e.g. '2h30m').
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(since, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if period, err := time.ParseDuration(since); err == nil {
+		return now.Add(-period), nil
+	}
+	if date, ok := parseDate(since); ok {
+		return date, nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid value '%s' for the '--since' flag: it must be a date, e.g. '2017-06-30', or a period of time, e.g. '2h' or '7d'", since)
+}
+
+func parseDate(value string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if date, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Whether a job has one of the statuses, if any, and was started after 'since', if set.
+// A job without a valid start date does not match a 'since' date.
+func matchesJob(job *client.Job, statuses []string, since time.Time) bool {
+	if len(statuses) != 0 {
+		found := false
+		for _, status := range statuses {
+			if strings.EqualFold(strings.TrimSpace(status), job.Status) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !since.IsZero() {
+		startDate, ok := parseDate(job.StartDate)
+		if !ok || startDate.Before(since) {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether a job is scheduled to start in the future, approved or not.
+func isScheduled(job *client.Job) bool {
+	return job.Status == client.StatusScheduled || job.Status == client.StatusRequestedScheduled
+}
+
+// Whether a job is scheduled before another one. The jobs without a valid
+// scheduled date go last.
+func scheduledBefore(a, b *client.Job) bool {
+	dateA, okA := parseDate(a.ScheduledDate)
+	dateB, okB := parseDate(b.ScheduledDate)
+	if okA != okB {
+		return okA
+	}
+	return dateA.Before(dateB)
+}
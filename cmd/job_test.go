@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"github.com/MidVision/rd/client"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMatchesJob(t *testing.T) {
+	now := time.Date(2017, 7, 10, 12, 0, 0, 0, time.Local)
+	since, err := parseSince("7d", now)
+	if err != nil || !since.Equal(time.Date(2017, 7, 3, 12, 0, 0, 0, time.Local)) {
+		t.Fatalf("unexpected since date: %v (%v)", since, err)
+	}
+	if since, _ := parseSince("2h", now); !since.Equal(now.Add(-2 * time.Hour)) {
+		t.Fatalf("unexpected since date: %v", since)
+	}
+	if _, err := parseSince("yesterday", now); err == nil {
+		t.Fatal("expected an invalid since value to be rejected")
+	}
+
+	job := &client.Job{Id: 1, Status: "FAILED", StartDate: "2017-07-05 10:00:00"}
+	if !matchesJob(job, []string{"completed", "failed"}, since) {
+		t.Fatal("expected the job to match")
+	}
+	if matchesJob(job, []string{"COMPLETED"}, time.Time{}) || matchesJob(job, nil, now) {
+		t.Fatal("expected the job not to match")
+	}
+	if matchesJob(&client.Job{StartDate: "invalid"}, nil, since) {
+		t.Fatal("expected a job without a valid start date not to match a since date")
+	}
+}
+
+func TestApprovalTimeout(t *testing.T) {
+	polls := 0
+	newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		w.Write([]byte(`<html><body><div/><div><div><ul><li><span>Job Status</span><span>REQUESTED</span></li></ul></div></div></body></html>`))
+	})
+
+	jobDetails, err := waitForJob(context.Background(), "42", "Deployment", nil, 100*time.Millisecond)
+	if err != nil || client.StatusExitCode(jobDetails.Status()) != client.ExitAwaitingApproval || polls != 2 {
+		t.Fatalf("expected to give up waiting for the approval after 2 polls, got %q after %v polls", jobDetails.Status(), polls)
+	}
+}
+
+func TestWaitForJobWithoutStatus(t *testing.T) {
+	newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><div/><div><div><ul><li><span>Job Id</span><span>42</span></li></ul></div></div></body></html>`))
+	})
+
+	if _, err := waitForJob(context.Background(), "42", "Deployment", nil, 0); err == nil {
+		t.Fatal("expected an error for a job without a status")
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local)
+	if at, err := parseSchedule("", "", now); err != nil || !at.IsZero() {
+		t.Fatalf("expected no schedule, got %v (%v)", at, err)
+	}
+	if at, err := parseSchedule("2026-10-20T02:00:00+01:00", "", now); err != nil || !at.Equal(time.Date(2026, 10, 20, 1, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected schedule: %v (%v)", at, err)
+	}
+	if at, err := parseSchedule("2026-10-20 02:00", "", now); err != nil || !at.Equal(time.Date(2026, 10, 20, 2, 0, 0, 0, time.Local)) {
+		t.Fatalf("expected a date in local time, got %v (%v)", at, err)
+	}
+	if at, err := parseSchedule("", "2d", now); err != nil || !at.Equal(now.Add(48*time.Hour)) {
+		t.Fatalf("unexpected schedule: %v (%v)", at, err)
+	}
+	for _, flags := range [][2]string{{"2026-10-20 02:00", "4h"}, {"tomorrow", ""}, {"", "soon"}, {"2017-01-01 00:00", ""}} {
+		if _, err := parseSchedule(flags[0], flags[1], now); err == nil {
+			t.Fatalf("expected an error for %q", flags)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A JSONPath template, e.g. '{.items[*].name}' or
+// '{range .items[*]}{.name}{"\n"}{end}', evaluated against the JSON document of
+// a result. It supports the subset of the kubectl syntax useful for the RapidDeploy
+// documents: fields, array indexes, wildcards, string literals and ranges.
+type jsonPath struct {
+	nodes []*jsonPathNode
+}
+
+type jsonPathNode struct {
+	// Literal text, if the node is not an expression
+	text string
+	// The path of an expression, or of the elements to iterate over for a range
+	path []jsonPathStep
+	// The template evaluated for each element of a range
+	body []*jsonPathNode
+}
+
+type jsonPathStep struct {
+	// A field name, '*' for every field or element, or empty for an index
+	field string
+	index int
+}
+
+func parseJsonPath(template string) (*jsonPath, error) {
+	nodes, rest, err := parseJsonPathNodes(template, false)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("Invalid JSONPath template '%s': unexpected {end}", template)
+	}
+	return &jsonPath{nodes}, nil
+}
+
+// Parses the nodes of a template until its end or, inside a range, until the
+// matching '{end}'. Returns the nodes and the rest of the template after '{end}'.
+func parseJsonPathNodes(template string, inRange bool) ([]*jsonPathNode, string, error) {
+	var nodes []*jsonPathNode
+	for template != "" {
+		start := strings.Index(template, "{")
+		if start < 0 {
+			nodes = append(nodes, &jsonPathNode{text: template})
+			template = ""
+			break
+		}
+		if start > 0 {
+			nodes = append(nodes, &jsonPathNode{text: template[:start]})
+		}
+		end := strings.Index(template[start:], "}")
+		if end < 0 {
+			return nil, "", fmt.Errorf("Invalid JSONPath template: unclosed '{'")
+		}
+		expr := strings.TrimSpace(template[start+1 : start+end])
+		template = template[start+end+1:]
+
+		switch {
+		case expr == "end":
+			if !inRange {
+				return nil, "", fmt.Errorf("Invalid JSONPath template: {end} without {range}")
+			}
+			return nodes, template, nil
+		case strings.HasPrefix(expr, "range "):
+			path, err := parseJsonPathSteps(strings.TrimSpace(strings.TrimPrefix(expr, "range ")))
+			if err != nil {
+				return nil, "", err
+			}
+			body, rest, err := parseJsonPathNodes(template, true)
+			if err != nil {
+				return nil, "", err
+			}
+			if body == nil && rest == template {
+				return nil, "", fmt.Errorf("Invalid JSONPath template: {range} without {end}")
+			}
+			nodes = append(nodes, &jsonPathNode{path: path, body: body})
+			template = rest
+		case strings.HasPrefix(expr, `"`):
+			text, err := strconv.Unquote(expr)
+			if err != nil {
+				return nil, "", fmt.Errorf("Invalid JSONPath string literal %s", expr)
+			}
+			nodes = append(nodes, &jsonPathNode{text: text})
+		default:
+			path, err := parseJsonPathSteps(expr)
+			if err != nil {
+				return nil, "", err
+			}
+			nodes = append(nodes, &jsonPathNode{path: path})
+		}
+	}
+	if inRange {
+		return nil, "", fmt.Errorf("Invalid JSONPath template: {range} without {end}")
+	}
+	return nodes, "", nil
+}
+
+// Parses a path like '$.items[0].name', '.items[*]' or '.jobId'.
+func parseJsonPathSteps(expr string) ([]jsonPathStep, error) {
+	path := []jsonPathStep{}
+	rest := strings.TrimPrefix(expr, "$")
+	if rest == "" || rest == "." || rest == "@" {
+		return path, nil
+	}
+	rest = strings.TrimPrefix(rest, "@")
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			field := rest[1 : end+1]
+			if field == "" {
+				return nil, fmt.Errorf("Invalid JSONPath expression '%s'", expr)
+			}
+			path = append(path, jsonPathStep{field: field})
+			rest = rest[end+1:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("Invalid JSONPath expression '%s': unclosed '['", expr)
+			}
+			subscript := strings.Trim(rest[1:end], `'"`)
+			if subscript == "*" {
+				path = append(path, jsonPathStep{field: "*"})
+			} else if index, err := strconv.Atoi(subscript); err == nil {
+				path = append(path, jsonPathStep{index: index})
+			} else {
+				path = append(path, jsonPathStep{field: subscript})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("Invalid JSONPath expression '%s'", expr)
+		}
+	}
+	return path, nil
+}
+
+// Evaluates the template against the JSON document and writes the result.
+func (jp *jsonPath) execute(w io.Writer, document []byte) error {
+	var data any
+	if err := unmarshalJSONNumbers(document, &data); err != nil {
+		return err
+	}
+	return executeJsonPathNodes(w, jp.nodes, data)
+}
+
+// Unmarshals a JSON document keeping its numbers as json.Number, so that they
+// are printed as they are, e.g. an id 1234567 instead of 1.234567e+06.
+func unmarshalJSONNumbers(document []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func executeJsonPathNodes(w io.Writer, nodes []*jsonPathNode, data any) error {
+	for _, node := range nodes {
+		if node.path == nil {
+			if _, err := io.WriteString(w, node.text); err != nil {
+				return err
+			}
+			continue
+		}
+		values := evalJsonPath(node.path, []any{data})
+		if node.body != nil {
+			for _, value := range values {
+				if err := executeJsonPathNodes(w, node.body, value); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		var texts []string
+		for _, value := range values {
+			texts = append(texts, jsonPathText(value))
+		}
+		if _, err := io.WriteString(w, strings.Join(texts, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the values reached by following the path from each of the given values.
+func evalJsonPath(path []jsonPathStep, values []any) []any {
+	for _, step := range path {
+		var next []any
+		for _, value := range values {
+			switch typed := value.(type) {
+			case map[string]any:
+				if step.field == "*" {
+					for _, child := range typed {
+						next = append(next, child)
+					}
+				} else if child, ok := typed[step.field]; ok {
+					next = append(next, child)
+				}
+			case []any:
+				if step.field == "*" {
+					next = append(next, typed...)
+				} else if step.field == "" {
+					index := step.index
+					if index < 0 {
+						index += len(typed)
+					}
+					if index >= 0 && index < len(typed) {
+						next = append(next, typed[index])
+					}
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+func jsonPathText(value any) string {
+	switch typed := value.(type) {
+	case string:
+		return typed
+	case nil:
+		return ""
+	case map[string]any, []any:
+		content, _ := json.Marshal(typed)
+		return string(content)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,380 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The flags of the list commands
+var (
+	listFilters []string
+	listSortBy  string
+	listColumns []string
+	noHeaders   bool
+	wideOutput  bool
+)
+
+// A column of a list in the table, CSV and TSV formats.
+type column struct {
+	name  string
+	value func(item any) string
+	// Hidden unless the '--wide' flag is given
+	wide bool
+}
+
+// Returns a column showing a value of the items of type T.
+func newColumn[T any](name string, value func(T) string) *column {
+	return &column{name: name, value: func(item any) string { return value(item.(T)) }}
+}
+
+// Returns a column only shown with the '--wide' flag.
+func wideColumn[T any](name string, value func(T) string) *column {
+	col := newColumn(name, value)
+	col.wide = true
+	return col
+}
+
+// A filter given with the '--filter' flag, e.g. 'product~=Linux'.
+type listFilter struct {
+	field    string
+	operator string
+	value    string
+	regexp   *regexp.Regexp
+}
+
+// An item of a list, with its JSON document to look up its fields.
+type listItem struct {
+	item     any
+	document any
+}
+
+// Adds the flags to filter, sort and select the columns of the items of a list.
+func addListFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&listFilters, "filter", nil, "Shows only the items matching 'FIELD=VALUE', 'FIELD!=VALUE' or 'FIELD~=REGEX', can be repeated. "+
+		"FIELD is a column or a field of the JSON document, e.g. 'enabled' or 'envType.live'.")
+	cmd.Flags().StringVar(&listSortBy, "sort-by", "", "Sorts the items by a column or a field of the JSON document. Prefix it with '-' to sort in descending order.")
+	cmd.Flags().StringSliceVar(&listColumns, "columns", nil, "Comma-separated list of the columns, or fields of the JSON document, shown in the table, CSV and TSV formats.")
+	cmd.Flags().BoolVar(&noHeaders, "no-headers", false, "Does not show the header in the table, CSV and TSV formats.")
+	cmd.Flags().BoolVar(&wideOutput, "wide", false, "Shows the wide columns, e.g. the hostnames of the servers.")
+}
+
+// Parses the filters given with the '--filter' flag.
+func parseFilters(expressions []string) ([]*listFilter, error) {
+	var filters []*listFilter
+	for _, expr := range expressions {
+		index := strings.Index(expr, "=")
+		if index < 1 {
+			return nil, fmt.Errorf("Invalid filter '%s', it must be 'FIELD=VALUE', 'FIELD!=VALUE' or 'FIELD~=REGEX'", expr)
+		}
+		filter := &listFilter{field: expr[:index], operator: "=", value: expr[index+1:]}
+		if operator := expr[index-1]; operator == '!' || operator == '~' {
+			filter.field, filter.operator = expr[:index-1], string(operator)+"="
+		}
+		if filter.field == "" {
+			return nil, fmt.Errorf("Invalid filter '%s': missing field", expr)
+		}
+		if filter.operator == "~=" {
+			var err error
+			if filter.regexp, err = regexp.Compile(filter.value); err != nil {
+				return nil, fmt.Errorf("Invalid regular expression in filter '%s': %v", expr, err)
+			}
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// Whether any of the values of the field matches the filter.
+func (f *listFilter) matches(values []string) bool {
+	matches := false
+	for _, value := range values {
+		switch f.operator {
+		case "~=":
+			matches = f.regexp.MatchString(value)
+		default:
+			matches = value == f.value
+		}
+		if matches {
+			break
+		}
+	}
+	if f.operator == "!=" {
+		return !matches
+	}
+	return matches
+}
+
+// Filters and sorts the items of a list and builds its rows from the
+// selected columns, as requested with the flags of the list commands.
+func (res *result) selectItems() error {
+	filters, err := parseFilters(listFilters)
+	if err != nil {
+		return err
+	}
+	sortField, descending := strings.CutPrefix(listSortBy, "-")
+
+	var items []*listItem
+	if res.data != nil {
+		slice := reflect.ValueOf(res.data)
+		for i := 0; i < slice.Len(); i++ {
+			item := &listItem{item: slice.Index(i).Interface()}
+			content, err := json.Marshal(item.item)
+			if err != nil {
+				return err
+			}
+			unmarshalJSONNumbers(content, &item.document)
+			items = append(items, item)
+		}
+	}
+	for _, field := range append(filterFields(filters), sortField) {
+		if field != "" && !res.hasField(items, field) {
+			return fmt.Errorf("Unknown field '%s'", field)
+		}
+	}
+
+	var selected []*listItem
+	for _, item := range items {
+		matches := true
+		for _, filter := range filters {
+			if !filter.matches(res.fieldValues(item, filter.field)) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			selected = append(selected, item)
+		}
+	}
+	if sortField != "" {
+		sort.SliceStable(selected, func(i, j int) bool {
+			a, b := firstValue(res.fieldValues(selected[i], sortField)), firstValue(res.fieldValues(selected[j], sortField))
+			if descending {
+				a, b = b, a
+			}
+			return lessValue(a, b)
+		})
+	}
+
+	// Keep the typed data for the documents
+	if res.data != nil {
+		data := reflect.MakeSlice(reflect.TypeOf(res.data), 0, len(selected))
+		for _, item := range selected {
+			data = reflect.Append(data, reflect.ValueOf(item.item))
+		}
+		res.data = data.Interface()
+	}
+
+	var headers []string
+	var getters []func(*listItem) string
+	if listColumns != nil {
+		for _, name := range listColumns {
+			name = strings.TrimSpace(name)
+			if col := res.column(name); col != nil {
+				headers = append(headers, col.name)
+				getters = append(getters, func(item *listItem) string { return col.value(item.item) })
+				continue
+			}
+			if !res.hasField(items, name) {
+				return fmt.Errorf("Unknown column '%s'", name)
+			}
+			field := name
+			headers = append(headers, field)
+			getters = append(getters, func(item *listItem) string {
+				return strings.Join(res.fieldValues(item, field), "\n")
+			})
+		}
+	} else {
+		for _, col := range res.columns {
+			if col.wide && !wideOutput {
+				continue
+			}
+			col := col
+			headers = append(headers, col.name)
+			getters = append(getters, func(item *listItem) string { return col.value(item.item) })
+		}
+	}
+
+	if !noHeaders {
+		res.header = headers
+	}
+	res.rows = nil
+	for _, item := range selected {
+		row := make([]string, len(getters))
+		for i, getter := range getters {
+			row[i] = getter(item)
+		}
+		res.rows = append(res.rows, row)
+	}
+	return nil
+}
+
+func filterFields(filters []*listFilter) []string {
+	var fields []string
+	for _, filter := range filters {
+		fields = append(fields, filter.field)
+	}
+	return fields
+}
+
+// Returns the column with the given name, ignoring the case and punctuation.
+func (res *result) column(name string) *column {
+	for _, col := range res.columns {
+		if fieldKey(col.name) == fieldKey(name) {
+			return col
+		}
+	}
+	return nil
+}
+
+// Whether the name is a column, a field of the type of the items or a field
+// of any of them, so the fields are known even when the list is empty.
+func (res *result) hasField(items []*listItem, name string) bool {
+	if res.column(name) != nil {
+		return true
+	}
+	if dataType := reflect.TypeOf(res.data); dataType != nil && dataType.Kind() == reflect.Slice && typeHasField(dataType.Elem(), name) {
+		return true
+	}
+	for _, item := range items {
+		if _, ok := lookupField(item.document, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the JSON document of a value of a type has a field, like lookupField.
+// Maps and interfaces may have any field.
+func typeHasField(t reflect.Type, name string) bool {
+	for _, key := range strings.Split(name, ".") {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Map, reflect.Interface:
+			return true
+		case reflect.Struct:
+			field, ok := jsonField(t, key)
+			if !ok {
+				return false
+			}
+			t = field
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the type of the JSON field of a struct type with the given name,
+// including the fields of its embedded structs.
+func jsonField(t reflect.Type, name string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tagName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tagName == "-" {
+			continue
+		}
+		if field.Anonymous && tagName == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if fieldType, ok := jsonField(embedded, name); ok {
+					return fieldType, true
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if fieldKey(valueOr(tagName, field.Name)) == fieldKey(name) {
+			return field.Type, true
+		}
+	}
+	return nil, false
+}
+
+// Returns the values of a field of the JSON document of an item, or the
+// value of the column with the same name. A field holding an array has
+// one value per element.
+func (res *result) fieldValues(item *listItem, name string) []string {
+	value, ok := lookupField(item.document, name)
+	if !ok {
+		if col := res.column(name); col != nil {
+			return []string{col.value(item.item)}
+		}
+		return []string{""}
+	}
+	if array, isArray := value.([]any); isArray {
+		var values []string
+		for _, element := range array {
+			values = append(values, jsonPathText(element))
+		}
+		return values
+	}
+	return []string{jsonPathText(value)}
+}
+
+// Returns the value of a field, given as a dot-separated path, of a JSON document.
+func lookupField(document any, name string) (any, bool) {
+	value := document
+	for _, key := range strings.Split(name, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		found := false
+		for field, fieldValue := range object {
+			if fieldKey(field) == fieldKey(key) {
+				value, found = fieldValue, true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// Returns the name of a field or column in lower case without punctuation,
+// so e.g. 'Enabled?' and 'enabled' are the same.
+func fieldKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r - 'A' + 'a'
+		}
+		return -1
+	}, name)
+}
+
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Compares two values as numbers if both are numeric, or as text otherwise.
+func lessValue(a, b string) bool {
+	numberA, errA := strconv.ParseFloat(a, 64)
+	numberB, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return numberA < numberB
+	}
+	return strings.ToLower(a) < strings.ToLower(b)
+}
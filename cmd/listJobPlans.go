@@ -3,27 +3,10 @@
 package cmd
 
 import (
-	"encoding/xml"
-	"fmt"
-	"github.com/olekukonko/tablewriter"
+	"github.com/MidVision/rd/client"
 	"github.com/spf13/cobra"
-	"net/http"
-	"os"
 	"strconv"
-)
-
-type (
-	JobPlans struct {
-		JobPlan []*JobPlan `xml:"JobPlan,omitempty"`
-	}
-
-	JobPlan struct {
-		Description  string `xml:"description,omitempty"`
-		Id           int    `xml:"id,omitempty"`
-		Name         string `xml:"name,omitempty"`
-		PlanData     string `xml:"planData,omitempty"`
-		SecurityName string `xml:"securityName,omitempty"`
-	}
+	"strings"
 )
 
 // listJobPlansCmd represents the listJobPlans command
@@ -32,45 +15,28 @@ var listJobPlansCmd = &cobra.Command{
 	Short: "Lists the available job plans in RapidDeploy.",
 	Long:  `Lists the available job plans in RapidDeploy.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		// Load the login session file - initialize the rdClient struct
-		if err := rdClient.loadLoginFile(); err != nil {
-			printStdError("\n%v\n\n", err)
-			os.Exit(1)
-		}
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
 
 		// Perform the REST call to get the data
-		resData, _, _ := rdClient.call(http.MethodGet, "deployment/jobPlan/list", nil, "text/xml")
-
-		// Initialize the object that will contain the unmarshalled XML response
-		rdJobPlans := new(JobPlans)
-		// Unmarshall the XML response
-		err := xml.Unmarshal(resData, &rdJobPlans)
-		if err != nil {
-			printStdError("\n%v\n\n", err)
-			os.Exit(1)
-		}
-
-		// FIXME: check the problem with the ASCII characters in the description!!!
-		//        There is some problem with the &#xD; character and printing the table.
-		// Print data in a table
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetAlignment(tablewriter.ALIGN_LEFT)
-		if len(rdJobPlans.JobPlan) != 0 {
-			table.SetHeader([]string{"ID", "Name", "Owner"}) //, "Description"})
-			for _, jobPlan := range rdJobPlans.JobPlan {
-				if len(jobPlan.Description) >= 0 {
-					table.Append([]string{strconv.Itoa(int(jobPlan.Id)), jobPlan.Name, jobPlan.SecurityName}) //, jobPlan.Description})
-				}
-			}
-		} else {
-			table.Append([]string{"No job plans available to show"})
-		}
-		fmt.Println()
-		table.Render()
-		fmt.Println()
+		rdJobPlans, err := rdClient.ListJobPlans(cmd.Context())
+		checkError(err)
+
+		// Print data in the selected output format
+		printResult(&result{kind: "JobPlanList", data: rdJobPlans, list: true, empty: "No job plans available to show",
+			columns: []*column{
+				newColumn("ID", func(jobPlan *client.JobPlan) string { return strconv.Itoa(int(jobPlan.Id)) }),
+				newColumn("Name", func(jobPlan *client.JobPlan) string { return jobPlan.Name }),
+				newColumn("Owner", func(jobPlan *client.JobPlan) string { return jobPlan.SecurityName }),
+				// The descriptions contain carriage returns (&#xD;) breaking the table
+				wideColumn("Description", func(jobPlan *client.JobPlan) string {
+					return strings.ReplaceAll(jobPlan.Description, "\r", "")
+				}),
+			}})
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(listJobPlansCmd)
+	addListFlags(listJobPlansCmd)
 }
@@ -3,11 +3,8 @@
 package cmd
 
 import (
-	"encoding/xml"
-	"fmt"
-	"github.com/olekukonko/tablewriter"
+	"github.com/MidVision/rd/client"
 	"github.com/spf13/cobra"
-	"net/http"
 	"os"
 )
 
@@ -22,44 +19,25 @@ var listTargetsCmd = &cobra.Command{
 		// Check the correct number of arguments
 		if len(args) != 1 {
 			cmd.Usage()
-			os.Exit(1)
+			os.Exit(client.ExitUsage)
 		} else {
 			projectName = args[0]
 		}
 
-		// Load the login session file - initialize the rdClient struct
-		if err := rdClient.loadLoginFile(); err != nil {
-			printStdError("\n%v\n\n", err)
-			os.Exit(1)
-		}
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
 
 		// Perform the REST call to get the data
-		resData, _, _ := rdClient.call(http.MethodGet, "project/"+projectName+"/list", nil, "text/xml", false)
-
-		// Initialize the object that will contain the unmarshalled XML response
-		rdTargets := new(Html)
-		// Unmarshall the XML response
-		err := xml.Unmarshal(resData, &rdTargets)
-		if err != nil {
-			printStdError("\n%v\n\n", err)
-			os.Exit(1)
-		}
+		rdTargets, err := rdClient.ListTargets(cmd.Context(), projectName)
+		checkError(err)
 
-		// Print data in a table
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetAlignment(tablewriter.ALIGN_LEFT)
-		if len(rdTargets.Body.Div[1].Div[0].Ul.Li) != 0 {
-			table.SetHeader([]string{"Targets"})
-			for _, target := range rdTargets.Body.Div[1].Div[0].Ul.Li {
-				table.Append([]string{target.Span[1]})
-			}
-		}
-		fmt.Println()
-		table.Render()
-		fmt.Println()
+		// Print data in the selected output format
+		printResult(&result{kind: "TargetList", data: rdTargets, list: true,
+			columns: []*column{newColumn("Targets", func(target string) string { return target })}})
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(listTargetsCmd)
+	addListFlags(listTargetsCmd)
 }
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"github.com/MidVision/rd/client"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSelectItems(t *testing.T) {
+	defer func() { listFilters, listSortBy, listColumns, noHeaders, wideOutput = nil, "", nil, false, false }()
+	newResult := func() *result {
+		servers := []*client.Server{
+			{Displayname: "web2", Product: "Linux", Hostnames: []string{"web2", "web2.local"}, ServerEnabled: true},
+			{Displayname: "db", Product: "Windows", ServerEnabled: true},
+			{Displayname: "web1", Product: "Linux", Hostnames: []string{"web1"}},
+		}
+		return &result{kind: "ServerList", data: servers, list: true, columns: []*column{
+			newColumn("Display name", func(server *client.Server) string { return server.Displayname }),
+			wideColumn("Hostnames", func(server *client.Server) string { return strings.Join(server.Hostnames, "\n") }),
+			newColumn("Enabled?", func(server *client.Server) string { return strconv.FormatBool(server.ServerEnabled) }),
+		}}
+	}
+
+	// Filters on a field and a column, sorted in descending order
+	listFilters, listSortBy = []string{"product~=^Lin", "enabled!=false"}, "-displayname"
+	res := newResult()
+	if err := res.selectItems(); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.rows) != 1 || res.rows[0][0] != "web2" || len(res.header) != 2 || len(res.data.([]*client.Server)) != 1 {
+		t.Fatalf("unexpected rows: %v %v", res.header, res.rows)
+	}
+
+	// Any element of an array matches
+	listFilters, listSortBy = []string{"hostnames=web2.local"}, ""
+	res = newResult()
+	if err := res.selectItems(); err != nil || len(res.rows) != 1 {
+		t.Fatalf("unexpected rows: %v (%v)", res.rows, err)
+	}
+
+	// Columns can be selected from the fields, and the wide columns shown
+	listFilters, listSortBy, listColumns, noHeaders = nil, "displayName", []string{"display name", "product"}, true
+	res = newResult()
+	if err := res.selectItems(); err != nil {
+		t.Fatal(err)
+	}
+	if res.header != nil || len(res.rows) != 3 || strings.Join(res.rows[0], ",") != "db,Windows" || res.rows[2][0] != "web2" {
+		t.Fatalf("unexpected rows: %v %v", res.header, res.rows)
+	}
+	listColumns, noHeaders, wideOutput = nil, false, true
+	res = newResult()
+	if res.selectItems(); len(res.header) != 3 {
+		t.Fatalf("expected the wide columns, got %v", res.header)
+	}
+
+	for _, filter := range []string{"=x", "unknown=x", "product~=("} {
+		listFilters = []string{filter}
+		if err := newResult().selectItems(); err == nil {
+			t.Fatalf("expected an error for the filter %q", filter)
+		}
+	}
+
+	// The fields of an empty list are known from the type of its items
+	listFilters, listSortBy, listColumns = []string{"product=Linux"}, "-version", []string{"hostname", "enabled?"}
+	res = newResult()
+	res.data = []*client.Server{}
+	if err := res.selectItems(); err != nil || len(res.rows) != 0 {
+		t.Fatalf("unexpected rows for an empty list: %v (%v)", res.rows, err)
+	}
+	listSortBy = "unknown"
+	if err := res.selectItems(); err == nil {
+		t.Fatal("expected an error for an unknown field of an empty list")
+	}
+}
+
+func TestSelectItemsByNumber(t *testing.T) {
+	defer func() { listFilters, listSortBy = nil, "" }()
+	environments := []*client.Environment{{Id: 1234567, Name: "PROD"}, {Id: 99, Name: "DEV"}, {Id: 1234568, Name: "TEST"}}
+	newResult := func() *result {
+		return &result{kind: "EnvironmentList", data: environments, list: true, columns: []*column{
+			newColumn("Name", func(environment *client.Environment) string { return environment.Name }),
+		}}
+	}
+
+	// The ids are matched as they are, not as floating point numbers
+	listFilters = []string{"id=1234567"}
+	res := newResult()
+	if err := res.selectItems(); err != nil || len(res.rows) != 1 || res.rows[0][0] != "PROD" {
+		t.Fatalf("unexpected rows: %v (%v)", res.rows, err)
+	}
+
+	// And sorted as numbers
+	listFilters, listSortBy = nil, "-id"
+	res = newResult()
+	if err := res.selectItems(); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.rows) != 3 || res.rows[0][0] != "TEST" || res.rows[2][0] != "DEV" {
+		t.Fatalf("unexpected order: %v", res.rows)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/MidVision/rd/client"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	textLogFormat = "text"
+	jsonLogFormat = "json"
+)
+
+// The log levels of the '--log-level' flag
+var logLevels = map[string]slog.Level{
+	"error": slog.LevelError,
+	"warn":  slog.LevelWarn,
+	"info":  slog.LevelInfo,
+	"debug": slog.LevelDebug,
+	"trace": client.LevelTrace,
+}
+
+var logLevel, logFile, logFormat string
+
+// The logger of the diagnostics of the commands and of the calls to the
+// server, separated from the output of the commands. It discards everything
+// until 'initLogger' is called.
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func init() {
+	RootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "", "Minimum level of the log entries: error, warn, info, debug or trace (headers and bodies of the calls). "+
+		"It defaults to 'info' with the 'log-file' option and otherwise nothing is logged.")
+	RootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Appends the log entries to this file instead of the standard error.")
+	RootCmd.PersistentFlags().StringVar(&logFormat, "log-format", textLogFormat, "Format of the log entries: text or json.")
+}
+
+// Creates the logger for the '--log-level', '--log-file' and '--log-format'
+// flags. The '--debug' flag is the same as '--log-level debug'.
+func initLogger() error {
+	level := logLevel
+	if level == "" {
+		switch {
+		case debug:
+			level = "debug"
+		case logFile != "":
+			level = "info"
+		default:
+			return nil
+		}
+	}
+	slogLevel, ok := logLevels[strings.ToLower(level)]
+	if !ok {
+		return fmt.Errorf("Invalid log level '%s', it must be one of: error, warn, info, debug, trace", level)
+	}
+
+	var out io.Writer = os.Stderr
+	if logFile != "" {
+		file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("Unable to open the log file: %v", err)
+		}
+		out = file
+	}
+
+	options := &slog.HandlerOptions{Level: slogLevel, ReplaceAttr: levelName}
+	var handler slog.Handler
+	switch logFormat {
+	case textLogFormat:
+		handler = slog.NewTextHandler(out, options)
+	case jsonLogFormat:
+		handler = slog.NewJSONHandler(out, options)
+	default:
+		return fmt.Errorf("Invalid log format '%s', it must be text or json", logFormat)
+	}
+	logger = slog.New(&redactingHandler{handler})
+	return nil
+}
+
+// Names the trace level 'TRACE' instead of 'DEBUG-4'.
+func levelName(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Key == slog.LevelKey && len(groups) == 0 {
+		if level, ok := attr.Value.Any().(slog.Level); ok && level == client.LevelTrace {
+			attr.Value = slog.StringValue("TRACE")
+		}
+	}
+	return attr
+}
+
+// Logs an entry at the trace level.
+func logTrace(msg string, args ...any) {
+	logger.Log(context.Background(), client.LevelTrace, msg, args...)
+}
+
+// A handler masking the secrets of the messages and the attributes of the log entries.
+type redactingHandler struct {
+	slog.Handler
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, redactor.Redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr))
+		return true
+	})
+	return h.Handler.Handle(ctx, redacted)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = redactAttr(attr)
+	}
+	return &redactingHandler{h.Handler.WithAttrs(redacted)}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{h.Handler.WithGroup(name)}
+}
+
+// Masks the secrets of an attribute. Errors and other values printed as text are masked as strings.
+func redactAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, redactor.Redact(value.String()))
+	case slog.KindGroup:
+		var attrs []any
+		for _, groupAttr := range value.Group() {
+			attrs = append(attrs, redactAttr(groupAttr))
+		}
+		return slog.Group(attr.Key, attrs...)
+	case slog.KindAny:
+		switch v := value.Any().(type) {
+		case error:
+			return slog.String(attr.Key, redactor.Redact(v.Error()))
+		case fmt.Stringer:
+			return slog.String(attr.Key, redactor.Redact(v.String()))
+		}
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/MidVision/rd/client"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	defer func(l *slog.Logger, r *client.Redactor) {
+		logger, redactor = l, r
+		logLevel, logFile, logFormat = "", "", textLogFormat
+	}(logger, redactor)
+
+	logFile = filepath.Join(t.TempDir(), "rd.log")
+	logFormat = jsonLogFormat
+	if err := initLogger(); err != nil {
+		t.Fatal(err)
+	}
+	redactor = client.NewRedactor()
+	redactor.AddSecret("s3cret-value")
+	logger.Debug("Not logged at the default info level")
+	logger.Info("Logging in", "password", "s3cret-value")
+	logger.Error("Command failed", "error", errors.New("bad token s3cret-value"), "exit_code", 3)
+
+	content, _ := os.ReadFile(logFile)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 || strings.Contains(string(content), "s3cret-value") {
+		t.Fatalf("unexpected log:\n%s", content)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "ERROR" || entry["error"] != "bad token "+client.Mask || entry["exit_code"] != 3.0 {
+		t.Fatalf("unexpected entry: %v", entry)
+	}
+
+	logLevel = "trace"
+	if err := initLogger(); err != nil {
+		t.Fatal(err)
+	}
+	logTrace("HTTP response body", "body", "<Projects/>")
+	content, _ = os.ReadFile(logFile)
+	if !strings.Contains(string(content), `"level":"TRACE"`) {
+		t.Fatalf("expected a trace entry:\n%s", content)
+	}
+
+	logLevel = "verbose"
+	if err := initLogger(); err == nil {
+		t.Fatal("expected an error for an invalid level")
+	}
+}
@@ -5,12 +5,12 @@
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"github.com/MidVision/rd/client"
 	"github.com/spf13/cobra"
 	"io/ioutil"
 	"net/http"
-	"net/url"
 	"os"
 	"text/tabwriter"
 )
@@ -19,12 +19,12 @@ const (
 	awsTokenUrl   = "http://169.254.169.254/latest/api/token"
 	instanceIdUrl = "http://169.254.169.254/latest/meta-data/instance-id"
 	machineIdFile = "/etc/machine-id"
+	defaultRdUrl  = "http://localhost:9090/MidVision"
 	defaultRdUser = "mvadmin"
 	defaultRdPass = "mvadmin"
 )
 
 // These values are set with the flags
-var rdUrl string
 var username string
 var password string
 
@@ -42,6 +42,16 @@ default passwords are tried in order: the AWS instance ID, the Azure
 machine ID and the default RapidDeploy password. To log in with custom
 credentials both '--username' and '--password' must be provided.
 
+The server URL is given with the '--url' flag or the RD_URL environment
+variable, and defaults to '` + defaultRdUrl + `'.
+
+The session is saved in the connection profile selected with the
+'--profile' flag or the RD_PROFILE environment variable, or in the
+current profile otherwise. See the 'profile' command to manage them.
+
+The TLS flags, e.g. '--ca-cert' or '--client-cert', are saved in the
+session as well and used by every later command.
+
 This session can be finished by calling the 'logout' command or by
 calling this command again.`,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -57,59 +67,65 @@ calling this command again.`,
 		if userSet != passSet {
 			printStdError("\nThe '--username' and '--password' flags must be provided together.\n")
 			printStdError("Run the command without them to log in with the default credentials.\n\n")
-			os.Exit(1)
+			os.Exit(client.ExitUsage)
+		}
+
+		if rdUrl == "" {
+			rdUrl = os.Getenv(urlEnvVar)
+		}
+		if rdUrl == "" {
+			rdUrl = defaultRdUrl
 		}
 
 		loginResult := false
 		if !userSet {
-			if debug {
-				fmt.Printf("[DEBUG] Loging in with default AWS password...\n")
-			}
+			logger.Debug("Logging in with the default AWS password")
 			// Get default AWS password and try first login
 			header := make(map[string]string)
 			header["X-aws-ec2-metadata-token-ttl-seconds"] = "21600"
 
-			awsToken, _, _ := call(http.MethodPut, awsTokenUrl, nil, header)
-			if debug {
-				fmt.Printf("[DEBUG] AWS API token = %v\n", string(awsToken))
+			awsToken, status, _ := call(cmd.Context(), http.MethodPut, awsTokenUrl, nil, header)
+			if status == http.StatusOK {
+				redactor.AddSecret(string(awsToken))
 			}
+			logTrace("AWS API token", "token", string(awsToken))
 
 			delete(header, "X-aws-ec2-metadata-token-ttl-seconds")
 			header["X-aws-ec2-metadata-token"] = string(awsToken)
 
-			instanceId, _, _ := call(http.MethodGet, instanceIdUrl, nil, header)
-			if debug {
-				fmt.Printf("[DEBUG] AWS instance ID = %v\n", string(instanceId))
+			instanceId, status, _ := call(cmd.Context(), http.MethodGet, instanceIdUrl, nil, header)
+			if status == http.StatusOK {
+				redactor.AddSecret(string(instanceId))
 			}
-			loginResult = checkLogin(rdUrl, username, string(instanceId))
+			logTrace("AWS instance ID", "instance_id", string(instanceId))
+			loginResult = checkLogin(cmd.Context(), rdUrl, username, string(instanceId))
 
 			if !loginResult {
 				// Get default Azure password and try second login
 				machineId, _ := ioutil.ReadFile(machineIdFile)
-				if debug {
-					fmt.Printf("[DEBUG] Loging in with default Azure password...\n")
-					fmt.Printf("[DEBUG] Azure machine ID = %v\n", string(machineId))
-				}
-				loginResult = checkLogin(rdUrl, username, string(machineId))
+				redactor.AddSecret(string(machineId))
+				logger.Debug("Logging in with the default Azure password")
+				logTrace("Azure machine ID", "machine_id", string(machineId))
+				loginResult = checkLogin(cmd.Context(), rdUrl, username, string(machineId))
 			}
 
 			if !loginResult {
 				// Try default RapidDeploy password
-				if debug {
-					fmt.Printf("[DEBUG] Loging in with default RapidDeploy password...\n")
-				}
-				loginResult = checkLogin(rdUrl, username, defaultRdPass)
+				logger.Debug("Logging in with the default RapidDeploy password")
+				loginResult = checkLogin(cmd.Context(), rdUrl, username, defaultRdPass)
 			}
 		} else {
-			loginResult = checkLogin(rdUrl, username, password)
+			loginResult = checkLogin(cmd.Context(), rdUrl, username, password)
 		}
 
 		if !loginResult {
 			printStdError("\nUnable to connect to server '%s'\n", rdUrl)
 			printStdError("Please check the credentials.\n\n")
-			os.Exit(1)
+			os.Exit(client.ExitUnauthorized)
 		}
 
+		warnInsecure(rdClient)
+
 		// Print successful login
 		w := new(tabwriter.Writer)
 		w.Init(os.Stdout, 0, 8, 1, '*', 0)
@@ -119,7 +135,7 @@ calling this command again.`,
 		w.Flush()
 
 		// Save the rdClient struct into the login session file for future calls to RapidDeploy
-		if err := rdClient.saveLoginFile(); err != nil {
+		if err := saveLoginFile(rdClient); err != nil {
 			printStdError("\n%v\n\n", err)
 		}
 	},
@@ -130,80 +146,33 @@ func init() {
 	RootCmd.AddCommand(loginCmd)
 
 	// The flags defined for this command
-	loginCmd.Flags().StringVar(&rdUrl, "url", "http://localhost:9090/MidVision", "URL used to connect to the RapidDeploy server.")
 	loginCmd.Flags().StringVar(&username, "username", defaultRdUser, "Username used to connect to the RapidDeploy server.")
 	loginCmd.Flags().StringVar(&password, "password", "", "Password used to connect to the RapidDeploy server.")
 }
 
-func checkLogin(loginUrl, loginUser, loginPass string) bool {
-	parsedUrl, err := url.Parse(loginUrl)
+func checkLogin(ctx context.Context, loginUrl, loginUser, loginPass string) bool {
+	rdc, err := client.NewRDClient(loginUrl, "")
 	if err != nil {
-		if debug {
-			fmt.Printf("[ERROR] Parse URL: %v\n", err)
-		}
+		logger.Debug("Invalid URL", "url", loginUrl, "error", err)
 		return false
 	}
+	configureClient(rdc)
 
-	// Initialize the rdClient
-	rdClient.BaseUrl = parsedUrl
-
-	// This is necessary so an error is not thrown for empty authentication token
-	rdClient.AuthToken = "token"
-
-	// The credentials are sent in a dedicated request struct so they never
-	// reach the persisted rdClient struct. The 'param1'/'param2' keys must
-	// always be present in the request body, even when the values are empty,
-	// otherwise the server fails with an internal error.
-	reqData, err := json.Marshal(struct {
-		BaseUrl   *url.URL `json:"url"`
-		AuthToken string   `json:"token"`
-		Username  string   `json:"param1"`
-		Password  string   `json:"param2"`
-	}{rdClient.BaseUrl, rdClient.AuthToken, loginUser, loginPass})
-	if err != nil {
-		if debug {
-			fmt.Printf("[ERROR] Marshal rdClient: %v\n", err)
+	logger.Debug("Trying to log in", "url", rdc.BaseUrl)
+	if err := rdc.CreateToken(ctx, loginUser, loginPass); err != nil {
+		if ctx.Err() != nil {
+			checkError(ctx.Err())
 		}
+		logger.Debug("Unable to create an authentication token", "url", rdc.BaseUrl, "error", err)
 		return false
 	}
-	resData, _, _ := rdClient.call(http.MethodPost, "user/create/token", reqData, "text/plain")
-	rdClient.AuthToken = string(resData)
-
-	if debug {
-		fmt.Printf("[DEBUG] Trying to log in to '%s'...\n", rdClient.BaseUrl)
-		fmt.Printf("[DEBUG] Authentication token = %v\n", rdClient.AuthToken)
-	}
 
 	// Perform a ramdom call to see the URL and authentication token are correct
-	// FIXME: to check connection use 'listGroups' until we create a generic web service call!
-	reqUrl, err := rdClient.BaseUrl.Parse(rdClient.BaseUrl.EscapedPath() + "/ws/group/list")
-	if err != nil {
-		if debug {
-			fmt.Printf("[ERROR] Parse URL: %v\n", err)
-		}
+	if err := rdc.CheckConnection(ctx); err != nil {
+		logger.Debug("Unable to connect to server", "url", rdc.BaseUrl, "error", err)
 		return false
 	}
-	header := make(map[string]string)
-	header["Content-Type"] = "text/xml"
-	header["Authorization"] = rdClient.AuthToken
-	_, statusCode, err := call(http.MethodGet, reqUrl.String(), nil, header)
 
-	// Login failed - the call throws an error
-	if err != nil {
-		if debug {
-			fmt.Printf("[DEBUG] Unable to connect to server '%s'\n", parsedUrl)
-			fmt.Printf("[DEBUG] %v\n", err)
-		}
-		return false
-	}
-
-	// Login failed - the call returns a failure status code
-	if statusCode != 200 {
-		if debug {
-			fmt.Printf("[DEBUG] Unable to connect to server '%s'\n", parsedUrl)
-			fmt.Printf("[DEBUG] Server returned response code %v: %v\n", statusCode, http.StatusText(statusCode))
-		}
-		return false
-	}
+	rdClient = rdc
 	return true
 }
@@ -15,7 +15,8 @@ var logoutCmd = &cobra.Command{
 	Short: "Finishes the session with the RapidDeploy server.",
 	Long: `This command finishes the session with the RapidDeploy server.
 
-It performs a logout from the RapidDeploy server.`,
+It performs a logout from the RapidDeploy server, removing the
+session of the selected connection profile.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if quiet {
 			os.Stdout = nil
@@ -25,7 +26,7 @@ It performs a logout from the RapidDeploy server.`,
 		w := new(tabwriter.Writer)
 		w.Init(os.Stdout, 0, 8, 1, '*', 0)
 		fmt.Fprintf(w, "\n\t\t\n")
-		if err := rdClient.removeLoginFile(); err != nil {
+		if err := removeLoginFile(); err != nil {
 			fmt.Fprintf(w, "\t WARNING: No login session found. Please, perform a login before requesting any action. \t\n")
 		} else {
 			fmt.Fprintf(w, "\t Successfully logged out from RapidDeploy. \t\n")
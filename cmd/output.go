@@ -0,0 +1,323 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MidVision/rd/client"
+	"github.com/olekukonko/tablewriter"
+	"go.yaml.in/yaml/v3"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+)
+
+const (
+	// Version of the schema of the documents printed in the machine-readable formats.
+	// Fields may be added to the documents, but never renamed or removed, within a version.
+	documentApiVersion = "rd/v1"
+
+	tableOutput = "table"
+	jsonOutput  = "json"
+	yamlOutput  = "yaml"
+	csvOutput   = "csv"
+	tsvOutput   = "tsv"
+
+	// Formats taking an expression, e.g. '-o jsonpath={.jobId}'
+	templateOutput = "template"
+	jsonPathOutput = "jsonpath"
+)
+
+var outputFormats = []string{tableOutput, jsonOutput, yamlOutput, csvOutput, tsvOutput,
+	templateOutput + "=TEMPLATE", jsonPathOutput + "=EXPRESSION"}
+
+// The format selected with the '--output' flag
+var outputFormat string
+
+// The expressions given with the '--template' and '--jsonpath' flags
+var outputTemplate, outputJsonPath string
+
+// The parsed expression of the template and jsonpath formats
+var (
+	parsedTemplate *template.Template
+	parsedJsonPath *jsonPath
+)
+
+// Where the result documents are written. In the machine-readable formats
+// os.Stdout is pointed to os.Stderr, so every other message printed by the
+// commands is kept apart from the document.
+var documentOutput io.Writer = os.Stdout
+
+// The result of a command, printed in the format selected with the '--output' flag.
+// The table and the CSV and TSV formats print the header and rows; the JSON and YAML
+// formats print a document with the typed data: for a list, an object with the
+// 'apiVersion', 'kind' and 'items' fields; otherwise the fields of the data
+// preceded by the 'apiVersion' and 'kind' fields. The rows of a list are built
+// from its columns, after filtering and sorting the items, in every format.
+type result struct {
+	// The kind of document, e.g. 'ProjectList' or 'Job'
+	kind string
+	// The typed data: a slice for a list, a struct otherwise
+	data any
+	list bool
+
+	// The columns of a list, replacing the header and rows
+	columns []*column
+
+	header []string
+	rows   [][]string
+	// Message shown in the table when there are no rows
+	empty string
+	// Merges the cells with equal values in the table
+	merge bool
+}
+
+// Checks the output format and separates the document from the other messages.
+func initOutput() error {
+	if format, expr, ok := strings.Cut(outputFormat, "="); ok {
+		switch format {
+		case templateOutput, "go-template":
+			outputFormat, outputTemplate = templateOutput, expr
+		case jsonPathOutput:
+			outputFormat, outputJsonPath = jsonPathOutput, expr
+		}
+	}
+	if outputTemplate != "" && outputJsonPath != "" {
+		return fmt.Errorf("The '--template' and '--jsonpath' flags cannot be used together")
+	}
+
+	var err error
+	switch {
+	case outputTemplate != "":
+		outputFormat = templateOutput
+		if parsedTemplate, err = template.New("output").Parse(outputTemplate); err != nil {
+			return fmt.Errorf("Invalid template: %v", err)
+		}
+	case outputJsonPath != "":
+		outputFormat = jsonPathOutput
+		if parsedJsonPath, err = parseJsonPath(outputJsonPath); err != nil {
+			return err
+		}
+	case outputFormat == templateOutput || outputFormat == jsonPathOutput:
+		return fmt.Errorf("The '%s' output format requires an expression, e.g. '-o %s=EXPRESSION'", outputFormat, outputFormat)
+	}
+
+	for _, format := range append(outputFormats, templateOutput, jsonPathOutput) {
+		if outputFormat == format {
+			if outputFormat != tableOutput {
+				documentOutput = os.Stdout
+				os.Stdout = os.Stderr
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid output format '%s', it must be one of: %s", outputFormat, strings.Join(outputFormats, ", "))
+}
+
+func machineOutput() bool {
+	return outputFormat != "" && outputFormat != tableOutput
+}
+
+func printResult(res *result) {
+	if res.columns != nil {
+		checkError(res.selectItems())
+	}
+	var err error
+	switch outputFormat {
+	case jsonOutput, yamlOutput:
+		err = printDocument(res)
+	case csvOutput, tsvOutput:
+		err = printRecords(res)
+	case templateOutput:
+		err = parsedTemplate.Execute(documentOutput, res.data)
+	case jsonPathOutput:
+		err = printJsonPath(res)
+	default:
+		printTable(res)
+	}
+	if err != nil {
+		checkError(err)
+	}
+}
+
+func printTable(res *result) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAutoMergeCells(res.merge)
+	if len(res.rows) != 0 {
+		if res.header != nil {
+			table.SetHeader(res.header)
+		}
+		table.AppendBulk(res.rows)
+	} else if res.empty != "" {
+		table.Append([]string{res.empty})
+	}
+	fmt.Println()
+	table.Render()
+	fmt.Println()
+}
+
+func printRecords(res *result) error {
+	w := csv.NewWriter(documentOutput)
+	if outputFormat == tsvOutput {
+		w.Comma = '\t'
+	}
+	records := res.rows
+	if res.header != nil {
+		records = append([][]string{res.header}, records...)
+	}
+	for _, record := range records {
+		if outputFormat == tsvOutput {
+			// A TSV record cannot span several lines
+			cleanRecord := make([]string, len(record))
+			for i, field := range record {
+				cleanRecord[i] = strings.NewReplacer("\n", ",", "\t", " ").Replace(field)
+			}
+			record = cleanRecord
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func printDocument(res *result) error {
+	content, err := marshalDocument(res)
+	if err != nil {
+		return err
+	}
+	if outputFormat == yamlOutput {
+		if content, err = jsonToYaml(content); err != nil {
+			return err
+		}
+	}
+	_, err = documentOutput.Write(content)
+	return err
+}
+
+// Evaluates the '--jsonpath' expression against the JSON document of a result.
+func printJsonPath(res *result) error {
+	content, err := marshalDocument(res)
+	if err != nil {
+		return err
+	}
+	return parsedJsonPath.execute(documentOutput, content)
+}
+
+// Returns the JSON document of a result.
+func marshalDocument(res *result) ([]byte, error) {
+	data := res.data
+	if res.list {
+		if data == nil {
+			data = []any{}
+		}
+		data = struct {
+			Items any `json:"items"`
+		}{data}
+	}
+	content, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Insert the 'apiVersion' and 'kind' fields before the fields of the data
+	document := fmt.Sprintf(`{"apiVersion":%q,"kind":%q`, documentApiVersion, res.kind)
+	if fields := strings.TrimPrefix(string(content), "{"); fields != "}" {
+		document += "," + fields
+	} else {
+		document += "}"
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(document), "", "  "); err != nil {
+		return nil, err
+	}
+	indented.WriteByte('\n')
+	return indented.Bytes(), nil
+}
+
+// Converts a JSON document to YAML, keeping the order of the fields.
+func jsonToYaml(content []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(content, &node); err != nil {
+		return nil, err
+	}
+	resetStyle(&node)
+	return yaml.Marshal(&node)
+}
+
+// JSON is parsed as YAML in flow style: switch to the default block style.
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
+
+// Prints an error as a JSON object on the standard error.
+func printJsonError(err error) {
+	jsonErr := struct {
+		Error      string   `json:"error"`
+		Kind       string   `json:"kind"`
+		StatusCode int      `json:"statusCode,omitempty"`
+		Messages   []string `json:"messages,omitempty"`
+	}{Error: redactor.Redact(err.Error()), Kind: errorKind(err)}
+	var resErr *client.ResponseError
+	if errors.As(err, &resErr) {
+		jsonErr.StatusCode = resErr.StatusCode
+		for _, message := range resErr.Messages {
+			jsonErr.Messages = append(jsonErr.Messages, redactor.Redact(message))
+		}
+	}
+	content, _ := json.Marshal(jsonErr)
+	fmt.Fprintln(os.Stderr, string(content))
+}
+
+// Returns the kind of an error, as shown in the JSON errors.
+func errorKind(err error) string {
+	var notFoundErr *client.NotFoundError
+	var unauthorizedErr *client.UnauthorizedError
+	var validationErr *client.ValidationError
+	var serverErr *client.ServerError
+	var resErr *client.ResponseError
+	var urlErr *url.Error
+	var sessionErr *sessionError
+	switch {
+	case errors.As(err, &sessionErr):
+		return "Unauthorized"
+	case errors.Is(err, context.Canceled):
+		return "Interrupted"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "Timeout"
+	case errors.As(err, &notFoundErr):
+		return "NotFound"
+	case errors.As(err, &unauthorizedErr):
+		return "Unauthorized"
+	case errors.As(err, &validationErr):
+		return "Validation"
+	case errors.As(err, &serverErr):
+		return "ServerError"
+	case errors.As(err, &resErr):
+		return "ResponseError"
+	case errors.As(err, &urlErr):
+		return "ConnectionError"
+	}
+	return "Error"
+}
+
+// Returns the result showing the details of a job.
+func jobResult(jobDetails client.JobDetails) *result {
+	res := &result{kind: "Job", data: jobDetails.Summary(), merge: true}
+	for _, message := range jobDetails {
+		res.rows = append(res.rows, []string{message.Title(), message.Value()})
+	}
+	return res
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"github.com/MidVision/rd/client"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMarshalDocument(t *testing.T) {
+	projects := []*client.Project{{Name: "app", Description: "My app"}}
+	content, err := marshalDocument(&result{kind: "ProjectList", data: projects, list: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "{\n  \"apiVersion\": \"rd/v1\",\n  \"kind\": \"ProjectList\",\n  \"items\": [") ||
+		!strings.Contains(string(content), `"name": "app"`) || !strings.Contains(string(content), `"enabled": false`) {
+		t.Fatalf("unexpected list document: %s", content)
+	}
+
+	// An empty list is an empty array, not null
+	content, _ = marshalDocument(&result{kind: "TargetList", list: true})
+	if !strings.Contains(string(content), `"items": []`) {
+		t.Fatalf("unexpected empty list document: %s", content)
+	}
+
+	// The fields of a single object follow the header fields
+	summary := client.JobDetails{{Span: []string{"Deployment Job ID", "42"}}}.Summary()
+	content, _ = marshalDocument(&result{kind: "Job", data: summary})
+	yamlContent, err := jsonToYaml(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(yamlContent), "apiVersion: rd/v1\nkind: Job\njobId: \"42\"\n") {
+		t.Fatalf("unexpected YAML document: %s", yamlContent)
+	}
+}
+
+func TestJsonPath(t *testing.T) {
+	projects := []*client.Project{{Name: "app"}, {Name: "web"}}
+	document, _ := marshalDocument(&result{kind: "ProjectList", data: projects, list: true})
+	for expr, expected := range map[string]string{
+		"{.kind}":                                "ProjectList",
+		"{.items[*].name}":                       "app web",
+		"{.items[-1].name}":                      "web",
+		`{range .items[*]}{.name}{"\n"}{end}`:    "app\nweb\n",
+		"name={.items[0].name}, missing={.none}": "name=app, missing=",
+	} {
+		jp, err := parseJsonPath(expr)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %q: %v", expr, err)
+		}
+		var out strings.Builder
+		if err := jp.execute(&out, document); err != nil || out.String() != expected {
+			t.Fatalf("expected %q for %q, got %q (%v)", expected, expr, out.String(), err)
+		}
+	}
+
+	// The numbers are printed as they are
+	jp, _ := parseJsonPath("{.items[*].id}")
+	var out strings.Builder
+	if err := jp.execute(&out, []byte(`{"items": [{"id": 1234567}, {"id": 1.5}]}`)); err != nil || out.String() != "1234567 1.5" {
+		t.Fatalf("unexpected numbers: %q (%v)", out.String(), err)
+	}
+	for _, expr := range []string{"{.items", "{range .items[*]}{.name}", "{end}", "{items}"} {
+		if _, err := parseJsonPath(expr); err == nil {
+			t.Fatalf("expected an error parsing %q", expr)
+		}
+	}
+}
+
+func TestTemplateOutput(t *testing.T) {
+	defer func(stdout *os.File) {
+		os.Stdout, documentOutput = stdout, stdout
+		outputFormat, outputTemplate = tableOutput, ""
+	}(os.Stdout)
+	outputFormat = "template={{range .}}{{.Name}} {{end}}"
+	if err := initOutput(); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	documentOutput = &out
+	printResult(&result{kind: "ProjectList", data: []*client.Project{{Name: "app"}, {Name: "web"}}, list: true})
+	if out.String() != "app web " {
+		t.Fatalf("unexpected template output: %q", out.String())
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/MidVision/rd/client"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+// A connection profile as listed.
+type profileSummary struct {
+	Name    string `json:"name"`
+	Url     string `json:"url,omitempty"`
+	Current bool   `json:"current"`
+}
+
+// profileCmd represents the profile command
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manages the named connection profiles.",
+	Long: `Manages the named connection profiles.
+
+Each profile keeps the session with a RapidDeploy server, created by
+running 'rd login --profile PROFILE_NAME'. The profile used by a command
+is the one given with the '--profile' flag, the one in the RD_PROFILE
+environment variable or the current profile, in this order.`,
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the connection profiles.",
+	Long:  `Lists the connection profiles. The current profile is marked with an asterisk.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if quiet {
+			os.Stdout = nil
+		}
+		sf := readProfiles()
+		profiles := []*profileSummary{}
+		for _, name := range sf.names() {
+			profile := &profileSummary{Name: name, Current: name == sf.Current}
+			if sf.Profiles[name].BaseUrl != nil {
+				profile.Url = sf.Profiles[name].BaseUrl.String()
+			}
+			profiles = append(profiles, profile)
+		}
+
+		// Print data in the selected output format
+		printResult(&result{kind: "ProfileList", data: profiles, list: true, empty: "No profiles available to show",
+			columns: []*column{
+				newColumn("Current", func(profile *profileSummary) string {
+					if profile.Current {
+						return "*"
+					}
+					return ""
+				}),
+				newColumn("Name", func(profile *profileSummary) string { return profile.Name }),
+				newColumn("URL", func(profile *profileSummary) string { return profile.Url }),
+			}})
+	},
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use PROFILE_NAME",
+	Short: "Sets the current connection profile.",
+	Long:  `Sets the profile used by the commands when no other one is selected.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if quiet {
+			os.Stdout = nil
+		}
+		if len(args) != 1 {
+			cmd.Usage()
+			os.Exit(client.ExitUsage)
+		}
+		sf := readProfiles()
+		checkProfileExists(sf, args[0])
+
+		sf.Current = args[0]
+		saveProfiles(sf)
+		printProfileMessage("Switched to profile '%s'", args[0])
+	},
+}
+
+var profileDeleteCmd = &cobra.Command{
+	Use:   "delete PROFILE_NAME",
+	Short: "Deletes a connection profile.",
+	Long:  `Deletes a connection profile, finishing its session with the RapidDeploy server.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if quiet {
+			os.Stdout = nil
+		}
+		if len(args) != 1 {
+			cmd.Usage()
+			os.Exit(client.ExitUsage)
+		}
+		sf := readProfiles()
+		checkProfileExists(sf, args[0])
+
+		delete(sf.Profiles, args[0])
+		if sf.Current == args[0] {
+			sf.Current = ""
+		}
+		saveProfiles(sf)
+		printProfileMessage("Profile '%s' deleted", args[0])
+	},
+}
+
+var profileRenameCmd = &cobra.Command{
+	Use:   "rename PROFILE_NAME NEW_PROFILE_NAME",
+	Short: "Renames a connection profile.",
+	Long:  `Renames a connection profile.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if quiet {
+			os.Stdout = nil
+		}
+		if len(args) != 2 {
+			cmd.Usage()
+			os.Exit(client.ExitUsage)
+		}
+		sf := readProfiles()
+		checkProfileExists(sf, args[0])
+		if _, ok := sf.Profiles[args[1]]; ok {
+			printStdError("\nProfile '%s' already exists.\n\n", args[1])
+			os.Exit(1)
+		}
+
+		sf.Profiles[args[1]] = sf.Profiles[args[0]]
+		delete(sf.Profiles, args[0])
+		if sf.Current == args[0] {
+			sf.Current = args[1]
+		}
+		saveProfiles(sf)
+		printProfileMessage("Profile '%s' renamed to '%s'", args[0], args[1])
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileUseCmd)
+	profileCmd.AddCommand(profileDeleteCmd)
+	profileCmd.AddCommand(profileRenameCmd)
+}
+
+func readProfiles() *sessionFile {
+	sf, err := readSessionFile()
+	if err != nil {
+		printStdError("\n%v\n\n", err)
+		os.Exit(1)
+	}
+	return sf
+}
+
+func saveProfiles(sf *sessionFile) {
+	if err := sf.save(); err != nil {
+		printStdError("\n%v\n\n", err)
+		os.Exit(1)
+	}
+}
+
+func checkProfileExists(sf *sessionFile, name string) {
+	if _, ok := sf.Profiles[name]; !ok {
+		printStdError("\nProfile '%s' not found.\n", name)
+		printStdError("Please, perform a login with the '--profile %s' flag to create it.\n\n", name)
+		os.Exit(client.ExitNotFound)
+	}
+}
+
+func printProfileMessage(format string, a ...any) {
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '*', 0)
+	fmt.Fprintf(w, "\n\t\t\n")
+	fmt.Fprintf(w, "\t "+format+" \t\n", a...)
+	fmt.Fprintf(w, "\t\t\n\n")
+	w.Flush()
+}
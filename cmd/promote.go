@@ -0,0 +1,359 @@
+// Copyright © 2024 Rafael Ruiz Palacios <support@midvision.com>
+
+package cmd
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"github.com/MidVision/rd/client"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Statuses of the projects of a promotion
+const (
+	promotionPlanned        = "PLANNED"
+	promotionUnchanged      = "UNCHANGED"
+	promotionSkipped        = "SKIPPED"
+	promotionPromoted       = "PROMOTED"
+	promotionFailed         = "FAILED"
+	promotionNotStarted     = "NOT_STARTED"
+	promotionRolledBack     = "ROLLED_BACK"
+	promotionRollbackFailed = "ROLLBACK_FAILED"
+	promotionNotRolledBack  = "NOT_ROLLED_BACK"
+)
+
+var promoteFrom, promoteTo string
+var promoteYes, promoteDryRun bool
+
+// A project promoted from one server to another.
+type promotion struct {
+	Project string `json:"project"`
+	// Whether the project already exists in the target server
+	Exists bool `json:"exists"`
+	// The files of the project archive added (+), removed (-) or changed (~) in the target server
+	Changes  []string `json:"changes,omitempty"`
+	Status   string   `json:"status"`
+	Error    string   `json:"error,omitempty"`
+	ExitCode int      `json:"exitCode"`
+
+	// The archive of the project in the source server, and in the target server if it exists
+	archive, previous []byte
+}
+
+var promoteCmd = &cobra.Command{
+	Use:   "promote PROJECT_NAME...",
+	Short: "Promotes projects from one RapidDeploy server to another.",
+	Long: `This command copies projects from the RapidDeploy server of the '--from'
+connection profile to the one of the '--to' profile, exporting them from the
+first one and importing them into the second one without writing them to disk.
+Log in to both servers first with 'rd login --profile PROFILE_NAME'. Each
+server is reached with the TLS options saved in its profile.
+
+The projects are promoted as a batch:
+
+  1. Every project is exported from both servers and the changes to its files
+     are shown. If any project cannot be exported, nothing is promoted.
+  2. The replacement of each project which already exists in the target server
+     must be confirmed, unless the '--yes' flag is given. The projects without
+     changes are not imported again.
+  3. The projects are imported one after the other. If an import fails, the
+     next projects are not imported and the projects already replaced are
+     rolled back to their previous version. The projects which are new in the
+     target server cannot be deleted: they are kept and shown as
+     ` + promotionNotRolledBack + `, to be deleted by hand if needed.
+
+With the '--dry-run' flag only the changes are shown. The command shows the
+outcome of each project and exits with the exit code of the worst one.
+
+The archives are not streamed: the one of each project in the source server
+and its previous version in the target server, kept for the rollback, are held
+in memory until the command ends. Promoting many large projects at once needs
+as much memory as all their archives.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if quiet {
+			os.Stdout = nil
+		}
+		// Check the correct number of arguments
+		if len(args) == 0 || promoteFrom == "" || promoteTo == "" {
+			cmd.Usage()
+			os.Exit(client.ExitUsage)
+		}
+		if promoteFrom == promoteTo {
+			printStdError("\nThe '--from' and '--to' profiles must be different.\n\n")
+			os.Exit(client.ExitUsage)
+		}
+
+		source, err := profileClient(promoteFrom)
+		checkError(err)
+		target, err := profileClient(promoteTo)
+		checkError(err)
+
+		promotions, err := preparePromotions(cmd.Context(), source, target, args)
+		checkError(err)
+		if !machineOutput() {
+			printPromotionChanges(promotions)
+		}
+
+		failed := slices.ContainsFunc(promotions, func(p *promotion) bool { return p.Status == promotionFailed })
+		if failed {
+			printStdError("\nNot every project could be exported, nothing promoted.\n")
+		}
+		if !failed && !promoteDryRun {
+			confirmPromotions(promotions)
+			promoteProjects(cmd.Context(), target, promotions)
+		}
+
+		// Print data in the selected output format
+		printResult(&result{kind: "PromotionList", data: promotions, list: true,
+			columns: []*column{
+				newColumn("Project", func(p *promotion) string { return p.Project }),
+				newColumn("Exists?", func(p *promotion) string { return strconv.FormatBool(p.Exists) }),
+				newColumn("Changes", func(p *promotion) string { return strconv.Itoa(len(p.Changes)) }),
+				newColumn("Status", func(p *promotion) string { return p.Status }),
+				newColumn("Error", func(p *promotion) string { return p.Error }),
+			}})
+		exitCodes := make([]int, len(promotions))
+		for i, p := range promotions {
+			exitCodes[i] = p.ExitCode
+		}
+		os.Exit(client.WorstExitCode(exitCodes...))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(promoteCmd)
+	promoteCmd.Flags().StringVar(&promoteFrom, "from", "", "Connection profile of the server to promote the projects from.")
+	promoteCmd.Flags().StringVar(&promoteTo, "to", "", "Connection profile of the server to promote the projects to.")
+	promoteCmd.Flags().BoolVarP(&promoteYes, "yes", "y", false, "Replaces the existing projects without asking for confirmation.")
+	promoteCmd.Flags().BoolVar(&promoteDryRun, "dry-run", false, "Shows the changes without promoting the projects.")
+}
+
+func (p *promotion) fail(status string, err error) {
+	p.Status, p.ExitCode = status, exitCode(err)
+	p.Error = strings.ReplaceAll(err.Error(), "\n", " ")
+}
+
+// Exports the projects from both servers and finds their changes.
+func preparePromotions(ctx context.Context, source, target *client.RDClient, projectNames []string) ([]*promotion, error) {
+	projects, err := target.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	promotions := make([]*promotion, len(projectNames))
+	for i, name := range projectNames {
+		p := &promotion{Project: name, Status: promotionPlanned}
+		promotions[i] = p
+		p.Exists = slices.ContainsFunc(projects, func(project *client.Project) bool { return project.Name == name })
+
+		logger.Debug("Exporting project", "project", name, "server", source.BaseUrl.String())
+		if p.archive, err = exportArchive(ctx, source, name); err != nil {
+			p.fail(promotionFailed, err)
+			continue
+		}
+		projectName, err := projectArchiveName(p.archive)
+		if err == nil && projectName != name {
+			err = fmt.Errorf("it contains project '%s'", projectName)
+		}
+		if err != nil {
+			p.fail(promotionFailed, fmt.Errorf("Invalid project archive exported: %v", err))
+			continue
+		}
+		if p.Exists {
+			logger.Debug("Exporting project", "project", name, "server", target.BaseUrl.String())
+			if p.previous, err = exportArchive(ctx, target, name); err != nil {
+				p.fail(promotionFailed, err)
+				continue
+			}
+		}
+		if p.Changes, err = archiveChanges(p.previous, p.archive); err != nil {
+			p.fail(promotionFailed, err)
+			continue
+		}
+		if p.Exists && len(p.Changes) == 0 {
+			p.Status = promotionUnchanged
+		}
+	}
+	return promotions, nil
+}
+
+// Returns the files added (+), removed (-) or changed (~) in a ZIP archive
+// compared to a previous version, which may be nil. The files are compared
+// by content, ignoring their dates.
+func archiveChanges(previous, current []byte) ([]string, error) {
+	previousFiles, err := archiveChecksums(previous)
+	if err != nil {
+		return nil, err
+	}
+	currentFiles, err := archiveChecksums(current)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range previousFiles {
+		names = append(names, name)
+	}
+	for name := range currentFiles {
+		if _, ok := previousFiles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	var changes []string
+	for _, name := range names {
+		previousSum, inPrevious := previousFiles[name]
+		currentSum, inCurrent := currentFiles[name]
+		switch {
+		case !inPrevious:
+			changes = append(changes, "+ "+name)
+		case !inCurrent:
+			changes = append(changes, "- "+name)
+		case previousSum != currentSum:
+			changes = append(changes, "~ "+name)
+		}
+	}
+	return changes, nil
+}
+
+// Returns the SHA-256 checksums of the files of a ZIP archive by name.
+func archiveChecksums(content []byte) (map[string][sha256.Size]byte, error) {
+	checksums := map[string][sha256.Size]byte{}
+	if content == nil {
+		return checksums, nil
+	}
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid ZIP file: %v", err)
+	}
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		reader, err := entry.Open()
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ZIP file: %s: %v", entry.Name, err)
+		}
+		hash := sha256.New()
+		_, err = io.Copy(hash, reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ZIP file: %s: %v", entry.Name, err)
+		}
+		checksums[entry.Name] = [sha256.Size]byte(hash.Sum(nil))
+	}
+	return checksums, nil
+}
+
+// Prints the changes of the projects in the target server.
+func printPromotionChanges(promotions []*promotion) {
+	fmt.Println()
+	for _, p := range promotions {
+		printPromotionChange(os.Stdout, p)
+	}
+}
+
+// Prints the changes of a project in the target server.
+func printPromotionChange(w io.Writer, p *promotion) {
+	switch {
+	case p.Status == promotionFailed:
+		fmt.Fprintf(w, "Project '%s': unable to promote it.\n", p.Project)
+	case len(p.Changes) == 0:
+		fmt.Fprintf(w, "Project '%s': no changes.\n", p.Project)
+	default:
+		if p.Exists {
+			fmt.Fprintf(w, "Project '%s': changes in profile '%s':\n", p.Project, promoteTo)
+		} else {
+			fmt.Fprintf(w, "Project '%s': new in profile '%s':\n", p.Project, promoteTo)
+		}
+		for _, change := range p.Changes {
+			fmt.Fprintln(w, "  "+change)
+		}
+	}
+}
+
+// Asks the user to confirm the replacement of each existing project, unless
+// the '--yes' flag is given, skipping the ones not confirmed. It exits if a
+// confirmation is needed but the standard input is not a terminal. With a
+// machine output format the changes to confirm are printed before asking, as
+// they were not printed to the standard output.
+func confirmPromotions(promotions []*promotion) {
+	if promoteYes {
+		return
+	}
+	var input *bufio.Reader
+	for _, p := range promotions {
+		if p.Status != promotionPlanned || !p.Exists {
+			continue
+		}
+		if input == nil {
+			if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+				printStdError("\nProject '%s' already exists in profile '%s' and no terminal is available to confirm its replacement.\n", p.Project, promoteTo)
+				printStdError("Please, use the '--yes' flag to replace the existing projects.\n\n")
+				os.Exit(client.ExitUsage)
+			}
+			input = bufio.NewReader(os.Stdin)
+		}
+		if machineOutput() {
+			fmt.Fprintln(os.Stderr)
+			printPromotionChange(os.Stderr, p)
+		}
+		fmt.Fprintf(os.Stderr, "\nReplace project '%s' in profile '%s'? [y/N] ", p.Project, promoteTo)
+		answer, _ := input.ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			p.Status = promotionSkipped
+		}
+	}
+}
+
+// Imports the planned projects into the target server one after the other.
+// When an import fails, the next projects are not imported and the projects
+// already replaced are rolled back to their previous version.
+func promoteProjects(ctx context.Context, target *client.RDClient, promotions []*promotion) {
+	var promoted []*promotion
+	for i, p := range promotions {
+		if p.Status != promotionPlanned {
+			continue
+		}
+		logger.Debug("Importing project", "project", p.Project, "server", target.BaseUrl.String())
+		fmt.Printf("Promoting project '%s'...\n", p.Project)
+		if err := target.Import(ctx, p.archive); err != nil {
+			p.fail(promotionFailed, err)
+			for _, next := range promotions[i+1:] {
+				if next.Status == promotionPlanned {
+					next.Status, next.ExitCode = promotionNotStarted, client.ExitCancelled
+				}
+			}
+			rollbackPromotions(ctx, target, promoted)
+			return
+		}
+		p.Status = promotionPromoted
+		promoted = append(promoted, p)
+	}
+}
+
+// Imports the previous version of promoted projects. The new projects are kept
+// and reported as not rolled back, as there is no web service to delete them.
+func rollbackPromotions(ctx context.Context, target *client.RDClient, promoted []*promotion) {
+	for _, p := range promoted {
+		if p.previous == nil {
+			p.fail(promotionNotRolledBack, fmt.Errorf("The project is new in profile '%s' and cannot be deleted, it is kept", promoteTo))
+			p.ExitCode = client.ExitFailed
+			continue
+		}
+		logger.Debug("Rolling back project", "project", p.Project, "server", target.BaseUrl.String())
+		fmt.Printf("Rolling back project '%s'...\n", p.Project)
+		if err := target.Import(ctx, p.previous); err != nil {
+			p.fail(promotionRollbackFailed, err)
+			continue
+		}
+		p.Status, p.ExitCode = promotionRolledBack, client.ExitCancelled
+	}
+}
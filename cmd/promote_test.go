@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"github.com/MidVision/rd/client"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArchiveChanges(t *testing.T) {
+	previous := zipArchive(t, map[string]string{"app.xml": "<Project><name>app</name></Project>", "old.sql": "drop", "same.txt": "same"})
+	current := zipArchive(t, map[string]string{"app.xml": "<Project><name>app</name><enabled>true</enabled></Project>", "new.sql": "create", "same.txt": "same"})
+	changes, err := archiveChanges(previous, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"~ app.xml", "+ new.sql", "- old.sql"}; !reflect.DeepEqual(changes, want) {
+		t.Fatalf("archiveChanges() = %q, want %q", changes, want)
+	}
+	if changes, _ := archiveChanges(nil, current); len(changes) != 3 {
+		t.Fatalf("expected every file of a new project to be added: %q", changes)
+	}
+}
+
+func TestPromoteProjects(t *testing.T) {
+	archives := map[string][]byte{}
+	for _, name := range []string{"app", "db", "web"} {
+		archives[name] = zipArchive(t, map[string]string{name + ".xml": "<Project><name>" + name + "</name></Project>"})
+	}
+	sourceClient := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/ws/project/"), "/export")
+		w.Write(archives[name])
+	})
+
+	// The target has an older 'db', imports of 'web' fail
+	oldDb := zipArchive(t, map[string]string{"db.xml": "<Project><name>db</name><description>old</description></Project>"})
+	var imported []string
+	targetClient := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ws/project/list":
+			w.Write([]byte("<Projects><Project><name>db</name></Project></Projects>"))
+		case "/ws/project/db/export":
+			w.Write(oldDb)
+		case "/ws/project/import":
+			content, _ := io.ReadAll(r.Body)
+			name, _ := projectArchiveName(content)
+			if name == "web" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			imported = append(imported, name)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	promotions, err := preparePromotions(context.Background(), sourceClient, targetClient, []string{"db", "app", "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !promotions[0].Exists || !reflect.DeepEqual(promotions[0].Changes, []string{"~ db.xml"}) || promotions[1].Exists {
+		t.Fatalf("unexpected promotions: %+v %+v", promotions[0], promotions[1])
+	}
+
+	promoteProjects(context.Background(), targetClient, promotions)
+	statuses := []string{promotionRolledBack, promotionNotRolledBack, promotionFailed}
+	for i, p := range promotions {
+		if p.Status != statuses[i] {
+			t.Errorf("unexpected status of project %s: %+v", p.Project, p)
+		}
+	}
+	// The old 'db' is imported again, the new 'app' is kept
+	if want := []string{"db", "app", "db"}; !reflect.DeepEqual(imported, want) {
+		t.Fatalf("imported %q, want %q", imported, want)
+	}
+	if client.WorstExitCode(promotions[0].ExitCode, promotions[1].ExitCode, promotions[2].ExitCode) == client.ExitOK {
+		t.Fatal("expected the partial failure to be reported in the exit code")
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/MidVision/rd/client"
+	"os"
+	"strings"
+)
+
+var sensitiveKeysPath string
+var debugUnsafe bool
+
+// Masks the secrets in the debugging information and the errors shown to the
+// user. It is nil, masking nothing, with the '--debug-unsafe' flag.
+var redactor = client.NewRedactor()
+
+func init() {
+	RootCmd.PersistentFlags().StringVar(&sensitiveKeysPath, "sensitive-keys", "", "File listing the data dictionary keys whose values are masked in the output, one per line, e.g. '@@DB_*@@'. "+
+		"The keys containing PASSWORD, PASSWD, SECRET, TOKEN, PRIVATE_KEY, API_KEY or CREDENTIAL are always masked.")
+	RootCmd.PersistentFlags().BoolVar(&debugUnsafe, "debug-unsafe", false, "Disables the masking of the authentication token, the passwords and the sensitive data dictionary values in the logs and the errors. "+
+		"The information logged is still selected with '--log-level' or '--debug'. UNSAFE! Only for local troubleshooting.")
+}
+
+// Creates the redactor for the '--sensitive-keys' and '--debug-unsafe' flags.
+func initRedactor() error {
+	if debugUnsafe {
+		printStdError("\n*** WARNING: The secrets are not masked with the '--debug-unsafe' flag, do not share the output! ***\n")
+		redactor = nil
+		return nil
+	}
+	if sensitiveKeysPath == "" {
+		return nil
+	}
+	keys, err := readSensitiveKeys(sensitiveKeysPath)
+	if err != nil {
+		return err
+	}
+	redactor = client.NewRedactor(keys...)
+	return nil
+}
+
+// Reads the patterns of the sensitive keys of a file, ignoring the empty
+// lines and the comments starting with '#'.
+func readSensitiveKeys(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the sensitive keys file: %v", err)
+	}
+	var keys []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			keys = append(keys, line)
+		}
+	}
+	return keys, nil
+}
@@ -5,39 +5,164 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"github.com/MidVision/rd/client"
 	"github.com/spf13/cobra"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 )
 
 // This is the client used to perform the REST calls to RapidDeploy.
 // It will be used in the different commands.
-var rdClient *RDClient = &RDClient{}
+var rdClient *client.RDClient = &client.RDClient{}
 
 // For debugging purposes
 var debug, quiet bool
 
+// Connection details overriding the login session
+var rdUrl, rdToken string
+
+// Maximum durations of the calls to RapidDeploy
+var timeout, transferTimeout, connectTimeout time.Duration
+
+// TLS configuration of the connection with RapidDeploy
+var caCert, clientCert, clientKey string
+var insecureSkipVerify bool
+var pinnedPublicKeys []string
+
+// Retry policy of the idempotent calls to RapidDeploy
+var retries int
+var retryMaxWait time.Duration
+
 // Version is set at build time via -ldflags from the Maven project version.
 var Version = "development"
 
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
-	Use:     "rd",
-	Short:   "Command line interface for the RapidDeploy tool.",
-	Long:    `RapidDeploy CLI - Command line interface for the RapidDeploy tool.`,
+	Use:   "rd",
+	Short: "Command line interface for the RapidDeploy tool.",
+	Long: `RapidDeploy CLI - Command line interface for the RapidDeploy tool.
+
+Exit codes:
+  0   Success
+  1   Error
+  2   Usage error: wrong command, flag or argument
+  3   Authentication failure or missing login session
+  4   Not found
+  5   Server error
+  6   Connection error
+  7   Interrupted, e.g. with Ctrl+C
+  8   Request to the server timed out
+  10  Job failed
+  11  Job rejected
+  12  Job cancelled
+  13  Job timed out
+  14  Job awaiting approval`,
 	Version: Version,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if err := initOutput(); err != nil {
+			printStdError("\n%v\n\n", err)
+			os.Exit(client.ExitUsage)
+		}
+		if err := initRedactor(); err != nil {
+			printStdError("\n%v\n\n", err)
+			os.Exit(client.ExitUsage)
+		}
+		if err := initLogger(); err != nil {
+			printStdError("\n%v\n\n", err)
+			os.Exit(client.ExitUsage)
+		}
+		logger.Info("Running command", "command", cmd.CommandPath(), "version", Version)
+		configureClient(rdClient)
+	},
 }
 
 // Execute adds all child commands to the root command sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := RootCmd.Execute(); err != nil {
+	// Cancel the request in flight when the user interrupts the command
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := RootCmd.ExecuteContext(ctx); err != nil {
+		// The commands handle their own errors: this is a wrong flag or command
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(client.ExitUsage)
 	}
 }
 
 func init() {
-	RootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Shows debugging information.")
+	RootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Shows debugging information. Same as '--log-level debug'.")
 	RootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Executes in quiet mode. Only shows error messages.")
+	RootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", tableOutput, "Output format: "+strings.Join(outputFormats, ", ")+". In the machine-readable formats the errors are printed as JSON objects.")
+	RootCmd.PersistentFlags().StringVar(&outputTemplate, "template", "", "Go template evaluated against the result, e.g. '{{range .}}{{.Name}} {{end}}' for a list. Same as '-o template=TEMPLATE'.")
+	RootCmd.PersistentFlags().StringVar(&outputJsonPath, "jsonpath", "", "JSONPath expression evaluated against the JSON document of the result, e.g. '{.jobId}' or '{.items[*].name}'. Same as '-o jsonpath=EXPRESSION'.")
+	RootCmd.PersistentFlags().StringVar(&rdUrl, "url", "", "URL used to connect to the RapidDeploy server. It overrides the RD_URL environment variable and the login session.")
+	RootCmd.PersistentFlags().StringVar(&rdToken, "token", "", "Authentication token used to connect to the RapidDeploy server. It overrides the RD_TOKEN environment variable and the login session.")
+	RootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "The connection profile to use. It defaults to the RD_PROFILE environment variable or the current profile.")
+	RootCmd.PersistentFlags().DurationVar(&timeout, "timeout", client.DefaultTimeout, "Maximum duration of each call to the RapidDeploy server, 0 means no limit.")
+	RootCmd.PersistentFlags().DurationVar(&transferTimeout, "transfer-timeout", client.DefaultTransferTimeout, "Maximum duration of each file transfer (export, import, logs) from or to the RapidDeploy server, 0 means no limit.")
+	RootCmd.PersistentFlags().DurationVar(&connectTimeout, "connect-timeout", client.DefaultConnectTimeout, "Maximum duration to establish the connection with the RapidDeploy server.")
+	RootCmd.PersistentFlags().StringVar(&caCert, "ca-cert", "", "PEM file with additional certificate authorities to trust. Saved in the session by the 'login' command.")
+	RootCmd.PersistentFlags().StringVar(&clientCert, "client-cert", "", "PEM file with the client certificate for mutual TLS. Saved in the session by the 'login' command.")
+	RootCmd.PersistentFlags().StringVar(&clientKey, "client-key", "", "PEM file with the private key of the client certificate. Saved in the session by the 'login' command.")
+	RootCmd.PersistentFlags().BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, "Disables the verification of the server certificate. INSECURE! Saved in the session by the 'login' command.")
+	RootCmd.PersistentFlags().StringSliceVar(&pinnedPublicKeys, "pin-sha256", nil, "Base64 SHA-256 hash of a public key accepted for the server, can be repeated. Saved in the session by the 'login' command.")
+	RootCmd.PersistentFlags().IntVar(&retries, "retries", client.DefaultRetries, "Number of times a failed read-only call is retried, 0 disables the retries. Calls starting a deployment are never retried.")
+	RootCmd.PersistentFlags().DurationVar(&retryMaxWait, "retry-max-wait", client.DefaultRetryMaxWait, "Maximum duration to wait between retries.")
+}
+
+// Applies the global flags to a RapidDeploy client.
+func configureClient(rdc *client.RDClient) {
+	rdc.Timeout = noLimit(timeout)
+	rdc.TransferTimeout = noLimit(transferTimeout)
+	rdc.ConnectTimeout = noLimit(connectTimeout)
+	rdc.Retries = retries
+	if retries == 0 {
+		rdc.Retries = -1
+	}
+	rdc.RetryMaxWait = retryMaxWait
+	if caCert != "" || clientCert != "" || clientKey != "" || insecureSkipVerify || len(pinnedPublicKeys) != 0 {
+		rdc.TLS = &client.TLSOptions{
+			CACertFile:         absPath(caCert),
+			ClientCertFile:     absPath(clientCert),
+			ClientKeyFile:      absPath(clientKey),
+			InsecureSkipVerify: insecureSkipVerify,
+			PinnedPublicKeys:   pinnedPublicKeys,
+		}
+	}
+	rdc.Logger = logger
+	rdc.Redactor = redactor
+}
+
+// Warns the user when the certificate of the server is not verified.
+func warnInsecure(rdc *client.RDClient) {
+	if rdc.TLS != nil && rdc.TLS.InsecureSkipVerify {
+		printStdError("\n*** WARNING: TLS certificate verification is disabled for server '%s'! ***\n", rdc.BaseUrl)
+		printStdError("*** The identity of the server is not checked and the connection is NOT secure. ***\n\n")
+	}
+}
+
+// Returns the absolute path of a file given as a flag, so it is
+// still found when saved in the session and used from another directory.
+func absPath(filePath string) string {
+	if filePath == "" {
+		return ""
+	}
+	if absFilePath, err := filepath.Abs(filePath); err == nil {
+		return absFilePath
+	}
+	return filePath
+}
+
+// The flags use 0 for no limit, the client a negative value.
+func noLimit(d time.Duration) time.Duration {
+	if d == 0 {
+		return -1
+	}
+	return d
 }
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"github.com/MidVision/rd/client"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Returns a client of a test server with the given handler, closed at the
+// end of the test. The client does not retry the failed calls.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *client.RDClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	rdc, err := client.NewRDClient(server.URL, "tok123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdc.Retries = -1
+	return rdc
+}
+
+// Starts a test server with the given handler and makes the commands use it
+// through 'rdClient' until the end of the test.
+func newTestServer(t *testing.T, handler http.HandlerFunc) *client.RDClient {
+	previous := rdClient
+	t.Cleanup(func() { rdClient = previous })
+	rdClient = newTestClient(t, handler)
+	return rdClient
+}
@@ -0,0 +1,72 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layout of the scheduled dates shown to the user, in local time.
+const scheduleDisplayLayout = "2006-01-02 15:04:05 MST"
+
+// The '--at' and '--in' flags
+var scheduleAt, scheduleIn string
+
+// Layouts accepted by the '--at' flag. The ones without a time zone are in local time.
+var scheduleLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"}
+
+// Adds the flags to schedule a job at a date or after a period of time.
+func addScheduleFlags(cmd *cobra.Command, action string) {
+	cmd.Flags().StringVar(&scheduleAt, "at", "", action+" at this date, e.g. '2026-10-20T02:00:00+01:00' or, in local time, '2026-10-20 02:00'.")
+	cmd.Flags().StringVar(&scheduleIn, "in", "", action+" after this period of time, e.g. '4h', '90m' or '2d'.")
+}
+
+// Returns the time given with the '--at' or '--in' flags, or the zero time if
+// none was given. The time must be after 'now'.
+func parseSchedule(at, in string, now time.Time) (time.Time, error) {
+	var scheduled time.Time
+	switch {
+	case at != "" && in != "":
+		return time.Time{}, fmt.Errorf("The '--at' and '--in' flags cannot be used together")
+	case at != "":
+		for _, layout := range scheduleLayouts {
+			if date, err := time.ParseInLocation(layout, at, time.Local); err == nil {
+				scheduled = date
+				break
+			}
+		}
+		if scheduled.IsZero() {
+			return time.Time{}, fmt.Errorf("Invalid date '%s' for the '--at' flag, e.g. '2026-10-20T02:00:00+01:00' or '2026-10-20 02:00'", at)
+		}
+	case in != "":
+		period, err := time.ParseDuration(in)
+		if days, ok := strings.CutSuffix(in, "d"); ok && err != nil {
+			var n int
+			if n, err = strconv.Atoi(days); err == nil {
+				period = time.Duration(n) * 24 * time.Hour
+			}
+		}
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Invalid period of time '%s' for the '--in' flag, e.g. '4h', '90m' or '2d'", in)
+		}
+		scheduled = now.Add(period)
+	default:
+		return time.Time{}, nil
+	}
+	if !scheduled.After(now) {
+		return time.Time{}, fmt.Errorf("The scheduled date %s is not in the future", scheduled.Local().Format(scheduleDisplayLayout))
+	}
+	return scheduled, nil
+}
+
+// Returns a date returned by the server in local time, or as it is if it cannot be parsed.
+func localDate(value string) string {
+	if date, ok := parseDate(value); ok {
+		return date.Local().Format(scheduleDisplayLayout)
+	}
+	return value
+}
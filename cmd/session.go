@@ -0,0 +1,304 @@
+// Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/MidVision/rd/client"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// The filename to save the connection details in the home folder.
+	loginFile = ".rapiddeploy"
+
+	// The profile used when none is selected.
+	defaultProfile = "default"
+
+	// Environment variable to select a profile, overridden by the '--profile' flag.
+	profileEnvVar = "RD_PROFILE"
+
+	// Environment variables to connect without a login session.
+	urlEnvVar      = "RD_URL"
+	tokenEnvVar    = "RD_TOKEN"
+	usernameEnvVar = "RD_USERNAME"
+	passwordEnvVar = "RD_PASSWORD"
+)
+
+// A missing or invalid session to connect to the RapidDeploy server.
+type sessionError struct {
+	error
+}
+
+func (e *sessionError) Unwrap() error {
+	return e.error
+}
+
+// The profile selected with the '--profile' flag
+var profileName string
+
+// Describes where the connection details of the session were taken from
+var sessionSource string
+
+// Initializes 'rdc' with the connection details given with the '--url' and
+// '--token' flags or the RD_* environment variables, in this order, without
+// touching the login session file. If none are given, the login session
+// file is loaded instead.
+func loadSession(ctx context.Context, rdc *client.RDClient) error {
+	sessionUrl, urlSource := rdUrl, "'--url' flag"
+	if sessionUrl == "" {
+		sessionUrl, urlSource = os.Getenv(urlEnvVar), urlEnvVar+" environment variable"
+	}
+	token, tokenSource := rdToken, "'--token' flag"
+	if token == "" {
+		token, tokenSource = os.Getenv(tokenEnvVar), tokenEnvVar+" environment variable"
+	}
+	envUsername, envPassword := os.Getenv(usernameEnvVar), os.Getenv(passwordEnvVar)
+
+	if sessionUrl == "" {
+		if token != "" || envUsername != "" || envPassword != "" {
+			return &sessionError{fmt.Errorf("No RapidDeploy server URL found!\nPlease, provide it with the '--url' flag or the %s environment variable.", urlEnvVar)}
+		}
+		if err := loadLoginFile(rdc); err != nil {
+			return &sessionError{err}
+		}
+		warnInsecure(rdc)
+		return nil
+	}
+
+	baseUrl, err := url.Parse(sessionUrl)
+	if err != nil {
+		return fmt.Errorf("Invalid RapidDeploy server URL '%s': %v", sessionUrl, err)
+	}
+	rdc.BaseUrl = baseUrl
+	warnInsecure(rdc)
+
+	if token != "" {
+		rdc.AuthToken = token
+		sessionSource = urlSource + " and " + tokenSource
+		return nil
+	}
+	if envUsername == "" && envPassword == "" {
+		// A login session for the same server, e.g. created by 'rd login' with the same URL
+		session := &client.RDClient{TLS: rdc.TLS}
+		if loadLoginFile(session) == nil && session.AuthToken != "" && sameUrl(session.BaseUrl, baseUrl) {
+			rdc.AuthToken = session.AuthToken
+			if rdc.TLS == nil {
+				rdc.TLS = session.TLS
+				warnInsecure(rdc)
+			}
+			sessionSource += " with the " + urlSource
+			return nil
+		}
+	}
+	if envUsername == "" || envPassword == "" {
+		return &sessionError{fmt.Errorf("No credentials found for server '%s'!\nPlease, provide the %s environment variable or both the %s and %s environment variables, or log in to it.",
+			sessionUrl, tokenEnvVar, usernameEnvVar, passwordEnvVar)}
+	}
+	if err := rdc.CreateToken(ctx, envUsername, envPassword); err != nil {
+		return fmt.Errorf("Unable to log in to server '%s' with the %s and %s environment variables.\n%w",
+			sessionUrl, usernameEnvVar, passwordEnvVar, err)
+	}
+	sessionSource = urlSource + ", " + usernameEnvVar + " and " + passwordEnvVar + " environment variables"
+	return nil
+}
+
+// Whether two server URLs are the same, ignoring a trailing slash.
+func sameUrl(a, b *url.URL) bool {
+	return a != nil && b != nil && strings.TrimSuffix(a.String(), "/") == strings.TrimSuffix(b.String(), "/")
+}
+
+// The content of the login session file: one set of connection details
+// per named profile and the profile used when none is selected.
+type sessionFile struct {
+	Current  string                      `json:"current,omitempty"`
+	Profiles map[string]*client.RDClient `json:"profiles"`
+}
+
+// Returns the name of the profile to use: the one given with the '--profile'
+// flag, the one in the RD_PROFILE environment variable, the current one in
+// the login session file or the default one, in this order.
+func activeProfile(sf *sessionFile) string {
+	if profileName != "" {
+		return profileName
+	}
+	if envProfile := os.Getenv(profileEnvVar); envProfile != "" {
+		return envProfile
+	}
+	if sf.Current != "" {
+		return sf.Current
+	}
+	return defaultProfile
+}
+
+// Reads the login session file. An empty session is returned if the file does not exist.
+func readSessionFile() (*sessionFile, error) {
+	loginFilePath := path.Join(getHome(), loginFile)
+
+	sf := &sessionFile{Profiles: map[string]*client.RDClient{}}
+	if _, err := os.Stat(loginFilePath); err != nil {
+		return sf, nil
+	}
+
+	content, err := ioutil.ReadFile(loginFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid login session found!\nPlease, perform a new login before requesting any action.")
+	}
+
+	// Login files written by older versions hold a single session at the top
+	// level: read both layouts and move a single session into the default profile.
+	var layouts struct {
+		sessionFile
+		client.RDClient
+	}
+	if err := json.Unmarshal(content, &layouts); err != nil {
+		return nil, fmt.Errorf("Invalid login session found!\nPlease, perform a new login before requesting any action.")
+	}
+	sf.Current = layouts.Current
+	for name, rdc := range layouts.Profiles {
+		sf.Profiles[name] = rdc
+	}
+	migrate := false
+	if layouts.Profiles == nil && layouts.BaseUrl != nil {
+		legacy := layouts.RDClient
+		sf.Profiles[defaultProfile] = &legacy
+		sf.Current = defaultProfile
+		migrate = true
+	}
+
+	// Login files written by older versions contain the credentials:
+	// scrub them from memory and rewrite the file without them.
+	for _, rdc := range sf.Profiles {
+		if rdc.Username != "" || rdc.Password != "" {
+			rdc.Username = ""
+			rdc.Password = ""
+			migrate = true
+		}
+	}
+	if migrate {
+		sf.save()
+	}
+	return sf, nil
+}
+
+func (sf *sessionFile) save() error {
+	loginFilePath := path.Join(getHome(), loginFile)
+
+	content, err := json.MarshalIndent(sf, "", "\t")
+	if err != nil {
+		return err
+	} else {
+		return ioutil.WriteFile(loginFilePath, content, 0600)
+	}
+}
+
+// Returns the names of the profiles in alphabetical order.
+func (sf *sessionFile) names() []string {
+	var names []string
+	for name := range sf.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Initializes 'rdc' with the connection details of the active profile.
+func loadLoginFile(rdc *client.RDClient) error {
+	sf, err := readSessionFile()
+	if err != nil {
+		return err
+	}
+
+	return loadProfile(sf, activeProfile(sf), rdc)
+}
+
+// Initializes 'rdc' with the connection details of a profile of the login session file.
+func loadProfile(sf *sessionFile, name string, rdc *client.RDClient) error {
+	profile, ok := sf.Profiles[name]
+	if !ok {
+		if len(sf.Profiles) == 0 {
+			return fmt.Errorf("No login session found!\nPlease, perform a login before requesting any action.")
+		}
+		return fmt.Errorf("No login session found for profile '%s'!\nPlease, perform a login before requesting any action.", name)
+	}
+
+	rdc.BaseUrl = profile.BaseUrl
+	rdc.AuthToken = profile.AuthToken
+	rdc.Username = profile.Username
+	rdc.Password = profile.Password
+	// The TLS flags override the configuration saved in the profile
+	if rdc.TLS == nil {
+		rdc.TLS = profile.TLS
+	}
+	sessionSource = fmt.Sprintf("profile '%s' of the login session file", name)
+	return nil
+}
+
+// Returns a client for the session of a profile, configured with the global
+// flags but the TLS ones: each profile may be of a server with its own
+// certificates, so it uses the TLS configuration saved in it.
+func profileClient(name string) (*client.RDClient, error) {
+	sf, err := readSessionFile()
+	if err != nil {
+		return nil, &sessionError{err}
+	}
+	rdc := &client.RDClient{}
+	configureClient(rdc)
+	rdc.TLS = nil
+	if err := loadProfile(sf, name, rdc); err != nil {
+		return nil, &sessionError{err}
+	}
+	warnInsecure(rdc)
+	return rdc, nil
+}
+
+// Saves the connection details of 'rdc' into the active profile.
+// The profile becomes the current one if there was none.
+func saveLoginFile(rdc *client.RDClient) error {
+	sf, err := readSessionFile()
+	if err != nil {
+		// Overwrite an invalid login session file
+		sf = &sessionFile{Profiles: map[string]*client.RDClient{}}
+	}
+
+	name := activeProfile(sf)
+	sf.Profiles[name] = &client.RDClient{
+		BaseUrl:   rdc.BaseUrl,
+		AuthToken: rdc.AuthToken,
+		Username:  rdc.Username,
+		Password:  rdc.Password,
+		TLS:       rdc.TLS,
+	}
+	if _, ok := sf.Profiles[sf.Current]; !ok {
+		sf.Current = name
+	}
+	return sf.save()
+}
+
+// Removes the active profile from the login session file.
+func removeLoginFile() error {
+	sf, err := readSessionFile()
+	if err != nil {
+		return err
+	}
+
+	name := activeProfile(sf)
+	if _, ok := sf.Profiles[name]; !ok {
+		return fmt.Errorf("No login session found for profile '%s'!", name)
+	}
+	delete(sf.Profiles, name)
+	if sf.Current == name {
+		sf.Current = ""
+	}
+	if len(sf.Profiles) == 0 {
+		return os.Remove(path.Join(getHome(), loginFile))
+	}
+	return sf.save()
+}
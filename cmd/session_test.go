@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/MidVision/rd/client"
+	homedir "github.com/mitchellh/go-homedir"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestLoginFileScrub(t *testing.T) {
+	u, _ := url.Parse("http://localhost:9090/MidVision")
+
+	// New-style save: credentials cleared before saving must not reach the file.
+	c := &client.RDClient{BaseUrl: u, AuthToken: "tok123", Username: "", Password: ""}
+	if err := saveLoginFile(c); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(path.Join(getHome(), loginFile))
+	if strings.Contains(string(content), "param1") || strings.Contains(string(content), "param2") {
+		t.Fatalf("credentials keys present in new-style login file: %s", content)
+	}
+
+	// Old-style file with plaintext credentials: load must scrub memory and rewrite the file.
+	// Old binaries saved the struct with the credentials still set, so simulate exactly that.
+	oldClient := &client.RDClient{BaseUrl: u, AuthToken: "tok123", Username: "mvadmin", Password: "secretpw"}
+	if err := saveLoginFile(oldClient); err != nil {
+		t.Fatal(err)
+	}
+	content, _ = os.ReadFile(path.Join(getHome(), loginFile))
+	if !strings.Contains(string(content), "secretpw") {
+		t.Fatalf("test setup failed to write old-style file with credentials: %s", content)
+	}
+	c2 := &client.RDClient{}
+	if err := loadLoginFile(c2); err != nil {
+		t.Fatal(err)
+	}
+	if c2.Username != "" || c2.Password != "" {
+		t.Fatalf("credentials not scrubbed from memory: %q %q", c2.Username, c2.Password)
+	}
+	if c2.AuthToken != "tok123" || c2.BaseUrl == nil {
+		t.Fatalf("token or url lost during migration: %+v", c2)
+	}
+	content, _ = os.ReadFile(path.Join(getHome(), loginFile))
+	if strings.Contains(string(content), "secretpw") || strings.Contains(string(content), "param2") {
+		t.Fatalf("old login file not rewritten, still contains credentials: %s", content)
+	}
+	if !strings.Contains(string(content), "tok123") {
+		t.Fatalf("rewritten login file lost the token: %s", content)
+	}
+}
+
+func TestLoginFileProfiles(t *testing.T) {
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(profileEnvVar, "")
+	loginFilePath := path.Join(getHome(), loginFile)
+
+	// A single-session file written by older versions is migrated into the default profile.
+	u, _ := url.Parse("http://localhost:9090/MidVision")
+	legacy, _ := json.Marshal(&client.RDClient{BaseUrl: u, AuthToken: "tok123", Username: "mvadmin", Password: "secretpw"})
+	if err := os.WriteFile(loginFilePath, legacy, 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := &client.RDClient{}
+	if err := loadLoginFile(c); err != nil {
+		t.Fatal(err)
+	}
+	if c.AuthToken != "tok123" || c.BaseUrl.String() != "http://localhost:9090/MidVision" {
+		t.Fatalf("legacy session not loaded: %+v", c)
+	}
+	sf, err := readSessionFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sf.Current != defaultProfile || sf.Profiles[defaultProfile] == nil {
+		t.Fatalf("legacy session not migrated into the default profile: %+v", sf)
+	}
+	content, _ := os.ReadFile(loginFilePath)
+	if strings.Contains(string(content), "secretpw") {
+		t.Fatalf("migrated login file still contains credentials: %s", content)
+	}
+
+	// Saving with another profile selected keeps the current profile unchanged.
+	profileName = "prod"
+	defer func() { profileName = "" }()
+	u, _ = url.Parse("https://prod:9090/MidVision")
+	if err := saveLoginFile(&client.RDClient{BaseUrl: u, AuthToken: "prodtok"}); err != nil {
+		t.Fatal(err)
+	}
+	if sf, _ = readSessionFile(); sf.Current != defaultProfile || len(sf.Profiles) != 2 {
+		t.Fatalf("unexpected profiles after saving 'prod': %+v", sf)
+	}
+
+	// The environment variable selects a profile when the flag is not set.
+	profileName = ""
+	t.Setenv(profileEnvVar, "prod")
+	c = &client.RDClient{}
+	if err := loadLoginFile(c); err != nil || c.AuthToken != "prodtok" {
+		t.Fatalf("profile from %s not loaded: %v %+v", profileEnvVar, err, c)
+	}
+
+	// The clients of the profiles use their own TLS options, not the ones of the flags.
+	profileName = "prod"
+	if err := saveLoginFile(&client.RDClient{BaseUrl: u, AuthToken: "prodtok", TLS: &client.TLSOptions{CACertFile: "/prod/ca.pem"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer func(file string) { caCert = file }(caCert)
+	caCert = "/other/ca.pem"
+	for name, caCertFile := range map[string]string{"prod": "/prod/ca.pem", defaultProfile: ""} {
+		rdc, err := profileClient(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rdc.TLS != nil && rdc.TLS.CACertFile != caCertFile || rdc.TLS == nil && caCertFile != "" {
+			t.Fatalf("unexpected TLS options of profile '%s': %+v", name, rdc.TLS)
+		}
+	}
+}
+
+func TestLoadSessionFromEnvironment(t *testing.T) {
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(urlEnvVar, "http://ci:9090/MidVision")
+	t.Setenv(tokenEnvVar, "envtok")
+
+	c := &client.RDClient{}
+	if err := loadSession(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	if c.BaseUrl.String() != "http://ci:9090/MidVision" || c.AuthToken != "envtok" {
+		t.Fatalf("session not taken from the environment: %+v", c)
+	}
+
+	// The flags override the environment variables.
+	rdToken = "flagtok"
+	defer func() { rdToken = "" }()
+	if err := loadSession(context.Background(), c); err != nil || c.AuthToken != "flagtok" {
+		t.Fatalf("token not taken from the flag: %v %+v", err, c)
+	}
+
+	// The login session file is never written.
+	if _, err := os.Stat(path.Join(getHome(), loginFile)); !os.IsNotExist(err) {
+		t.Fatalf("login session file written: %v", err)
+	}
+}
+
+func TestLoadSessionWithEnvironmentUrl(t *testing.T) {
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(profileEnvVar, "")
+	t.Setenv(tokenEnvVar, "")
+	t.Setenv(usernameEnvVar, "")
+	t.Setenv(passwordEnvVar, "")
+
+	// Logged in with RD_URL set, as 'rd login' uses it
+	t.Setenv(urlEnvVar, "http://ci:9090/MidVision/")
+	loginUrl, _ := url.Parse("http://ci:9090/MidVision")
+	if err := saveLoginFile(&client.RDClient{BaseUrl: loginUrl, AuthToken: "savedtok"}); err != nil {
+		t.Fatal(err)
+	}
+	c := &client.RDClient{}
+	if err := loadSession(context.Background(), c); err != nil || c.AuthToken != "savedtok" {
+		t.Fatalf("session not taken from the login session file: %v %+v", err, c)
+	}
+
+	// The session of another server is not used
+	t.Setenv(urlEnvVar, "http://other:9090/MidVision")
+	if err := loadSession(context.Background(), &client.RDClient{}); err == nil {
+		t.Fatal("expected an error without credentials for the server")
+	}
+}
@@ -1,21 +1,22 @@
 // Copyright © 2017 Rafael Ruiz Palacios <support@midvision.com>
 
-// TODO: synchronous deploy.
-
 package cmd
 
 import (
-	"encoding/xml"
+	"context"
+	"errors"
 	"fmt"
-	"github.com/olekukonko/tablewriter"
+	"github.com/MidVision/rd/client"
 	"github.com/spf13/cobra"
-	"net/http"
 	"os"
+	"slices"
 	"strconv"
-	"strings"
+	"time"
 )
 
-var jobPlanId string
+var jobPlanId, jobPlanLogDir string
+var jobPlanSync bool
+var jobPlanWaitTimeout time.Duration
 
 // startJobPlanCmd represents the startJobPlan command
 var startJobPlanCmd = &cobra.Command{
@@ -24,7 +25,21 @@ var startJobPlanCmd = &cobra.Command{
 	Long: `Starts a RapidDeploy job plan.
 
 In order to provide a job plan ID you previously
-may need to run the 'listJobPlans' command.`,
+may need to run the 'listJobPlans' command.
+
+With the '--at' or '--in' flags the job plan is scheduled to start later,
+the dates without a time zone are in local time.
+
+With the '--sync' flag the command waits for the job plan to finish, showing
+the status of each of its steps, and exits with the exit code for the final
+status of the job plan or of its first job which did not finish successfully.
+If the job plan does not finish within the '--wait-timeout', it exits with
+code 13 (timed out). If the job plan or one of its jobs waits for an approval
+for longer than the '--approval-timeout', or still waits for one when the
+'--wait-timeout' is reached, it exits with code 14 (awaiting approval).
+
+The jobs whose log cannot be saved with the '--logdir' flag are reported and
+the next logs are saved anyway.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if quiet {
 			os.Stdout = nil
@@ -34,48 +49,205 @@ may need to run the 'listJobPlans' command.`,
 			jobPlanId = args[0]
 		} else {
 			cmd.Usage()
-			os.Exit(1)
+			os.Exit(client.ExitUsage)
 		}
 
-		if _, err := strconv.Atoi(jobPlanId); err != nil {
+		jobPlanIdNum, err := strconv.Atoi(jobPlanId)
+		if err != nil {
 			printStdError("\nInvalid job plan ID provided, it must be a numeric value.\n\n")
-			os.Exit(1)
+			os.Exit(client.ExitUsage)
 		}
-
-		// Load the login session file - initialize the rdClient struct
-		if err := rdClient.loadLoginFile(); err != nil {
+		scheduledDate, err := parseSchedule(scheduleAt, scheduleIn, time.Now())
+		if err != nil {
 			printStdError("\n%v\n\n", err)
-			os.Exit(1)
+			os.Exit(client.ExitUsage)
 		}
 
-		// Perform the REST call to get the data
-		resData, _, _ := rdClient.call(http.MethodPut, "deployment/jobPlan/run/"+jobPlanId, nil, "text/xml", false)
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
 
-		// Initialize the object that will contain the unmarshalled XML response
-		rdDeploy := new(Html)
-		// Unmarshall the XML response
-		err := xml.Unmarshal(resData, &rdDeploy)
-		if err != nil {
-			printStdError("\n%v\n\n", err)
-			os.Exit(1)
-		}
+		// Perform the REST call to start the job plan
+		jobDetails, err := rdClient.RunJobPlan(cmd.Context(), jobPlanIdNum, scheduledDate)
+		checkError(err)
 
-		// Print data in a table
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetAlignment(tablewriter.ALIGN_LEFT)
-		if len(rdDeploy.Body.Div[1].Div[0].Ul.Li) != 0 {
-			table.SetAutoMergeCells(true)
-			for _, message := range rdDeploy.Body.Div[1].Div[0].Ul.Li {
-				replacedTitle := strings.Replace(message.Span[1], "com.midvision.rapiddeploy.domain.jobplan.", "", -1)
-				table.Append([]string{message.Span[0], replacedTitle})
+		// Print data in the selected output format.
+		// The machine-readable formats show the final state of the job plan instead.
+		if !jobPlanSync || !machineOutput() {
+			printResult(jobResult(jobDetails))
+			if !scheduledDate.IsZero() {
+				fmt.Printf("Job plan scheduled for %s\n\n", scheduledDate.Local().Format(scheduleDisplayLayout))
 			}
 		}
+		if !jobPlanSync {
+			return
+		}
+
+		fmt.Println("Running job plan in synchronous mode...")
+		run, waitExitCode := checkSynchronousJobPlan(cmd.Context(), jobDetails.Id())
+		logExitCode := client.ExitOK
+		if jobPlanLogDir != "" {
+			logExitCode = saveJobPlanLogs(cmd.Context(), run, jobPlanLogDir)
+		}
 		fmt.Println()
-		table.Render()
-		fmt.Println()
+
+		if machineOutput() {
+			printResult(&result{kind: "JobPlanRun", data: run})
+		} else {
+			printResult(&result{kind: "JobList", data: run.Jobs, list: true, empty: "No jobs spawned by the job plan",
+				columns: []*column{
+					newColumn("Step", func(job *client.Job) string { return job.Step }),
+					newColumn("Job ID", func(job *client.Job) string { return strconv.Itoa(job.Id) }),
+					newColumn("Project", func(job *client.Job) string { return job.ProjectName }),
+					newColumn("Target", func(job *client.Job) string { return job.Target }),
+					newColumn("Status", func(job *client.Job) string { return job.Status }),
+				}})
+		}
+
+		switch {
+		case waitExitCode == client.ExitTimedOut:
+			printStdError("Timed out after %v waiting for the job plan to finish.\n\n", jobPlanWaitTimeout)
+			os.Exit(waitExitCode)
+		case waitExitCode == client.ExitAwaitingApproval:
+			printStdError("The job plan was not approved in time.\n\n")
+			os.Exit(waitExitCode)
+		case !run.Successful():
+			printStdError("The job plan finished with status: %s\n\n", run.Status)
+			os.Exit(jobPlanExitCode(run))
+		case logExitCode != client.ExitOK:
+			printStdError("The job plan finished successfully, but not every job log could be saved.\n\n")
+			os.Exit(logExitCode)
+		}
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(startJobPlanCmd)
+	startJobPlanCmd.Flags().BoolVarP(&jobPlanSync, "sync", "s", false, "Waits for the job plan and all of its jobs to finish.")
+	// Not '--timeout', which is the global limit of each call to the server
+	startJobPlanCmd.Flags().DurationVar(&jobPlanWaitTimeout, "wait-timeout", 0, "Maximum duration to wait for the job plan to finish with the 'sync' option, 0 means no limit. "+
+		"When it is reached the command exits with code 13 (timed out).")
+	startJobPlanCmd.Flags().DurationVar(&approvalTimeout, "approval-timeout", 0, "Maximum duration to wait for the job plan or each of its jobs to be approved with the 'sync' option, 0 means no limit. "+
+		"When it is reached the command exits with code 14 (awaiting approval).")
+	startJobPlanCmd.Flags().StringVarP(&jobPlanLogDir, "logdir", "l", "", "Saves the log of each job of the job plan into this directory. "+
+		"Without a value ('-l' or '--logdir') they are saved into the current directory. It must be used with the 'sync' option.")
+	startJobPlanCmd.Flags().Lookup("logdir").NoOptDefVal = "."
+	addScheduleFlags(startJobPlanCmd, "Schedules the job plan")
+}
+
+// Waits for a job plan and the jobs it spawns to finish, printing the status
+// of the job plan and of each job when it changes. Returns the last state of
+// the job plan and ExitOK if it finished, or the exit code for giving up
+// waiting: ExitTimedOut when the '--wait-timeout' is reached, ExitAwaitingApproval
+// when the job plan or a job is still waiting for an approval then or after
+// the '--approval-timeout'.
+func checkSynchronousJobPlan(ctx context.Context, runId string) (*client.JobPlanRun, int) {
+	waitCtx := ctx
+	if jobPlanWaitTimeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, jobPlanWaitTimeout)
+		defer cancel()
+	}
+
+	run := &client.JobPlanRun{JobSummary: &client.JobSummary{JobId: runId, Details: []*client.JobDetail{}}, Jobs: []*client.Job{}}
+	jobStatuses := map[int]string{}
+	timeToSleep := 0 * time.Second
+	awaitingApproval := false
+	var requestedSince time.Time
+	timedOut := func() (*client.JobPlanRun, int) {
+		if awaitingApproval {
+			return run, client.ExitAwaitingApproval
+		}
+		return run, client.ExitTimedOut
+	}
+	for {
+		select {
+		case <-time.After(timeToSleep):
+		case <-waitCtx.Done():
+		}
+		if waitCtx.Err() != nil && ctx.Err() == nil {
+			return timedOut()
+		}
+		checkError(ctx.Err())
+
+		lastStatus := run.Status
+		lastRun, err := rdClient.GetJobPlanRun(waitCtx, runId)
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil && waitCtx.Err() != nil {
+			return timedOut()
+		}
+		checkError(err)
+		run = lastRun
+
+		if run.Status != lastStatus {
+			fmt.Println("> Job plan status: " + run.Status)
+		}
+		for _, job := range run.Jobs {
+			if jobStatuses[job.Id] != job.Status {
+				jobStatuses[job.Id] = job.Status
+				fmt.Printf("  > Step '%s', job %d on '%s': %s\n", job.Step, job.Id, job.Target, job.Status)
+			}
+		}
+
+		if run.Finished() {
+			fmt.Println("Job plan finished with status: " + run.Status)
+			return run, client.ExitOK
+		}
+		if client.IsPending(run.Status) {
+			timeToSleep = 30 * time.Second
+		} else {
+			timeToSleep = 5 * time.Second
+		}
+
+		awaitingApproval = client.StatusExitCode(run.Status) == client.ExitAwaitingApproval ||
+			slices.ContainsFunc(run.Jobs, func(job *client.Job) bool { return client.StatusExitCode(job.Status) == client.ExitAwaitingApproval })
+		if !awaitingApproval {
+			requestedSince = time.Time{}
+			continue
+		}
+		if requestedSince.IsZero() {
+			requestedSince = time.Now()
+		}
+		if approvalTimeout > 0 {
+			remaining := approvalTimeout - time.Since(requestedSince)
+			if remaining <= 0 {
+				fmt.Printf("  No approval received in %v, giving up waiting.\n", approvalTimeout)
+				return run, client.ExitAwaitingApproval
+			}
+			timeToSleep = min(timeToSleep, remaining)
+		}
+	}
+}
+
+// Saves the log of each job of a job plan into a directory. A log which
+// cannot be saved, e.g. of a job which never ran, is reported and the next
+// ones are saved anyway. Returns the worst exit code of the failures.
+func saveJobPlanLogs(ctx context.Context, run *client.JobPlanRun, logDir string) int {
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		printStdError("\nUnable to create directory: %s\n", logDir)
+		printStdError("%v\n\n", err)
+		return client.ExitError
+	}
+	worst := client.ExitOK
+	for _, job := range run.Jobs {
+		jobId := strconv.Itoa(job.Id)
+		if err := saveJobLog(ctx, jobId, jobLogPath(logDir, jobId, nil)); err != nil {
+			checkError(ctx.Err())
+			printStdError("Unable to save the log of job %s (%s): %v\n", jobId, job.Status, err)
+			worst = client.WorstExitCode(worst, exitCode(err))
+		}
+	}
+	return worst
+}
+
+// Returns the exit code for the final status of a job plan which did not
+// finish successfully: the one for its own status or for its first failed job.
+func jobPlanExitCode(run *client.JobPlanRun) int {
+	if exitCode := client.StatusExitCode(run.Status); exitCode != client.ExitOK {
+		return exitCode
+	}
+	for _, job := range run.Jobs {
+		if exitCode := client.StatusExitCode(job.Status); exitCode != client.ExitOK {
+			return exitCode
+		}
+	}
+	return client.ExitFailed
 }
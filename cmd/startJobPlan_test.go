@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"github.com/MidVision/rd/client"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSynchronousJobPlanTimeouts(t *testing.T) {
+	jobStatus := client.StatusRequested
+	newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/jobs") {
+			w.Write([]byte(`<DeploymentJobs><DeploymentJob><id>1</id><status>` + jobStatus + `</status></DeploymentJob></DeploymentJobs>`))
+			return
+		}
+		w.Write([]byte(`<html><body><div/><div><div><ul><li><span>Job Status</span><span>EXECUTING</span></li></ul></div></div></body></html>`))
+	})
+	defer func() { approvalTimeout, jobPlanWaitTimeout = 0, 0 }()
+	approvalTimeout = 100 * time.Millisecond
+
+	// The job plan is executing, but its job waits for an approval
+	run, exitCode := checkSynchronousJobPlan(context.Background(), "42")
+	if exitCode != client.ExitAwaitingApproval || run.Status != client.StatusExecuting || len(run.Jobs) != 1 {
+		t.Fatalf("expected to give up waiting for the approval with the last state, got %v %+v", exitCode, run)
+	}
+
+	// The job plan keeps executing past the wait timeout
+	jobStatus, approvalTimeout, jobPlanWaitTimeout = client.StatusExecuting, 0, 100*time.Millisecond
+	if run, exitCode = checkSynchronousJobPlan(context.Background(), "42"); exitCode != client.ExitTimedOut || run.Status != client.StatusExecuting {
+		t.Fatalf("expected the wait to time out with the last state, got %v %+v", exitCode, run)
+	}
+}
+
+func TestSaveJobPlanLogs(t *testing.T) {
+	newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ws/deployment/showlog/job/2" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("log of job 2"))
+	})
+
+	// The log of the job which never ran is missing, the next one is saved anyway
+	dir := t.TempDir()
+	run := &client.JobPlanRun{Jobs: []*client.Job{{Id: 1, Status: client.StatusUnexecutable}, {Id: 2, Status: client.StatusCompleted}}}
+	if exitCode := saveJobPlanLogs(context.Background(), run, dir); exitCode != client.ExitNotFound {
+		t.Fatalf("expected the missing log to be reported, got %v", exitCode)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "job-2.log")); string(content) != "log of job 2" {
+		t.Fatalf("unexpected log content: %q", content)
+	}
+}
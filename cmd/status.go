@@ -5,7 +5,6 @@ package cmd
 import (
 	"fmt"
 	"github.com/spf13/cobra"
-	"net/http"
 	"os"
 	"text/tabwriter"
 )
@@ -15,26 +14,32 @@ var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Checks if a login session is established.",
 	Long: `Checks if a login session is established to
-a RapidDeploy server and shows the server URL.`,
+a RapidDeploy server and shows the server URL and
+where the credentials of the session were taken from.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if quiet {
 			os.Stdout = nil
 		}
 		// Load the login session file
-		if err := rdClient.loadLoginFile(); err != nil {
-			printStdError("\n%v\n\n", err)
-			os.Exit(1)
-		}
+		checkError(loadSession(cmd.Context(), rdClient))
 
 		// Check connection to the server
-		// FIXME: to check connection use 'listGroups' until we create a generic web service call!
-		rdClient.call(http.MethodGet, "group/list", nil, "text/plain")
+		checkError(rdClient.CheckConnection(cmd.Context()))
 
 		// Session active - print message
+		if machineOutput() {
+			printResult(&result{kind: "Status", data: struct {
+				Url    string `json:"url"`
+				Source string `json:"source"`
+			}{rdClient.BaseUrl.String(), sessionSource},
+				header: []string{"URL", "Source"}, rows: [][]string{{rdClient.BaseUrl.String(), sessionSource}}})
+			return
+		}
 		w := new(tabwriter.Writer)
 		w.Init(os.Stdout, 0, 8, 1, '*', 0)
 		fmt.Fprintf(w, "\n\t\t\n")
 		fmt.Fprintf(w, "\t Successfully logged in to '%s' \t\n", rdClient.BaseUrl.String())
+		fmt.Fprintf(w, "\t Credentials taken from the %s \t\n", sessionSource)
 		fmt.Fprintf(w, "\t\t\n\n")
 		w.Flush()
 	},
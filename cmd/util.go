@@ -2,10 +2,14 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"github.com/MidVision/rd/client"
 	homedir "github.com/mitchellh/go-homedir"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -54,29 +58,28 @@ func getHome() string {
 // Should always be 'false' by default.
 var genXML bool = false
 
-func call(method string, reqUrlStr string, bodyContent []byte, header map[string]string) ([]byte, int, error) {
+// Performs a call to a service other than RapidDeploy, e.g. a cloud metadata
+// service, with the '--connect-timeout' and '--timeout' limits of the calls to
+// the server. The TLS options and pinned keys of the server are not used.
+func call(ctx context.Context, method string, reqUrlStr string, bodyContent []byte, header map[string]string) ([]byte, int, error) {
 
-	httpClient := &http.Client{Timeout: 5 * time.Second}
+	dialer := &net.Dialer{Timeout: connectTimeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+	httpClient := &http.Client{Transport: transport, Timeout: timeout}
 
 	// Parse the URL for the request
 	reqUrl, err := url.Parse(reqUrlStr)
 	if err != nil {
-		if debug {
-			fmt.Printf("[ERROR] Parse URL: %v\n", err)
-		}
+		logger.Debug("Invalid URL", "url", reqUrlStr, "error", err)
 		return nil, -1, err
 	}
 
-	if debug {
-		fmt.Printf("[DEBUG] Request body = %v\n", string(bodyContent))
-	}
-
 	// Create the HTTP request
-	req, err := http.NewRequest(method, reqUrl.String(), bytes.NewBuffer(bodyContent))
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl.String(), bytes.NewBuffer(bodyContent))
 	if err != nil {
-		if debug {
-			fmt.Printf("[ERROR] HTTP New Request: %v\n", err)
-		}
+		logger.Debug("Unable to create the HTTP request", "url", reqUrlStr, "error", err)
 		return nil, -1, err
 	}
 
@@ -85,34 +88,28 @@ func call(method string, reqUrlStr string, bodyContent []byte, header map[string
 		req.Header.Add(key, value)
 	}
 
-	if debug {
-		fmt.Printf("[DEBUG] Request URL = %v\n", req.URL)
-		fmt.Printf("[DEBUG] Request method = %v\n", req.Method)
-		fmt.Printf("[DEBUG] Request header = %v\n", req.Header)
-	}
+	logTrace("HTTP request header", "url", reqUrlStr, "header", fmt.Sprint(req.Header))
+	logTrace("HTTP request body", "url", reqUrlStr, "body", string(bodyContent))
 
 	// Perform the request
+	start := time.Now()
 	res, err := httpClient.Do(req)
 	if err != nil {
-		if debug {
-			fmt.Printf("[ERROR] HTTP Client: %v\n", err)
-		}
+		logger.Debug("HTTP request failed", "method", method, "url", reqUrlStr, "latency", time.Since(start), "error", err)
 		return nil, -1, err
 	}
 
 	// Read the response
 	resData, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		if debug {
-			fmt.Printf("[ERROR] Read response: %v\n", err)
-		}
+		logger.Debug("Unable to read the HTTP response", "method", method, "url", reqUrlStr, "error", err)
 		return nil, -1, err
 	}
 
-	if debug {
-		fmt.Printf("[DEBUG] Response code = %v\n", res.StatusCode)
-		fmt.Printf("[DEBUG] Response body = %v\n", string(resData))
-	}
+	// The responses of the metadata services are tokens and default passwords,
+	// registered as secrets by the callers once they are used as such
+	logger.Debug("HTTP request", "method", method, "url", reqUrlStr, "latency", time.Since(start), "status", res.StatusCode)
+	logTrace("HTTP response body", "url", reqUrlStr, "body", client.Mask)
 
 	// This is just for development purposes.
 	// It will genenerate a file with the XML response
@@ -127,6 +124,58 @@ func call(method string, reqUrlStr string, bodyContent []byte, header map[string
 	return resData, res.StatusCode, nil
 }
 
+// Prints the error returned by a RapidDeploy client operation, if any, and exits.
+func checkError(err error) {
+	if err == nil {
+		return
+	}
+	logger.Error("Command failed", "error", err, "exit_code", exitCode(err))
+	if machineOutput() {
+		printJsonError(err)
+		os.Exit(exitCode(err))
+	}
+	var notFoundErr *client.NotFoundError
+	var unauthorizedErr *client.UnauthorizedError
+	var validationErr *client.ValidationError
+	var resErr *client.ResponseError
+	var urlErr *url.Error
+	if errors.Is(err, context.Canceled) {
+		printStdError("\nOperation interrupted.\n\n")
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		printStdError("\nUnable to connect to server '%s'\n", rdClient.BaseUrl)
+		printStdError("The operation timed out, the '--timeout' and '--transfer-timeout' flags can be used to increase its limit.\n\n")
+	} else if errors.As(err, &unauthorizedErr) {
+		printStdError("\nUnable to connect to server '%s'\n", unauthorizedErr.Url)
+		printStdError("%v\n\n", err)
+		printStdError("Please, perform a new login before requesting any action.\n\n")
+	} else if errors.As(err, &resErr) {
+		if (errors.As(err, &notFoundErr) || errors.As(err, &validationErr)) && len(resErr.Messages) != 0 {
+			// The server explains why the request was rejected
+			printStdError("\n%v\n\n", strings.Join(resErr.Messages, "\n"))
+		} else {
+			printStdError("\nUnable to connect to server '%s'\n", resErr.Url)
+			printStdError("%v\n\n", err)
+		}
+	} else if errors.As(err, &urlErr) {
+		printStdError("\nUnable to connect to server '%s'\n", rdClient.BaseUrl)
+		printStdError("%v\n\n", err)
+	} else {
+		printStdError("\n%v\n\n", err)
+	}
+	os.Exit(exitCode(err))
+}
+
+// Returns the exit code for an error: a missing or invalid session is
+// reported as an authentication failure.
+func exitCode(err error) int {
+	var sessionErr *sessionError
+	if errors.As(err, &sessionErr) {
+		return client.ExitUnauthorized
+	}
+	return client.ExitCode(err)
+}
+
+// Prints an error message with its secrets masked.
 func printStdError(format string, a ...any) (n int, err error) {
-	return fmt.Fprintf(os.Stderr, format, a...)
+	return fmt.Fprint(os.Stderr, redactor.Redact(fmt.Sprintf(format, a...)))
 }
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"context"
+	"github.com/MidVision/rd/client"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCallTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+	// The TLS options of the RapidDeploy server are not used for other services
+	defer func(rdc *client.RDClient) { rdClient = rdc }(rdClient)
+	rdClient = &client.RDClient{TLS: &client.TLSOptions{CACertFile: "missing.pem"}}
+	defer func(d time.Duration) { timeout = d }(timeout)
+	timeout = 50 * time.Millisecond
+
+	if _, _, err := call(context.Background(), http.MethodGet, server.URL, nil, nil); err == nil {
+		t.Fatal("expected the call to be limited by the '--timeout' flag")
+	}
+	timeout = time.Second
+	if _, status, err := call(context.Background(), http.MethodGet, server.URL, nil, nil); err != nil || status != http.StatusOK {
+		t.Fatalf("unexpected call result: %v %v", status, err)
+	}
+}
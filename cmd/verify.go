@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"github.com/spf13/cobra"
 	"io"
-	"net/http"
 	"os"
 	"path"
 	"path/filepath"
@@ -35,16 +34,14 @@ logs of the server in a ZIP file for further investigation.`,
 		defer func() {
 			os.Exit(retcode)
 		}()
-		// Load the login session file - initialize the rdClient struct
-		if err := rdClient.loadLoginFile(); err != nil {
-			printStdError("\n%v\n\n", err)
-			os.Exit(1)
-		}
+		// Load the login session - initialize the rdClient struct
+		checkError(loadSession(cmd.Context(), rdClient))
 
 		/*************** Retrieve system info ***************/
-		resData, _, _ := rdClient.call(http.MethodGet, "system/general-info", nil, "text/xml")
+		resData, err := rdClient.GetSystemInfo(cmd.Context())
+		checkError(err)
 		systemInfoFilePath := filepath.Join(os.TempDir(), systemInfoFilename)
-		err := os.WriteFile(systemInfoFilePath, resData, 0644)
+		err = os.WriteFile(systemInfoFilePath, resData, 0644)
 		if err != nil {
 			printStdError("\nUnable to create file: %s\n", systemInfoFilePath)
 			printStdError("%v\n\n", err)
@@ -52,7 +49,8 @@ logs of the server in a ZIP file for further investigation.`,
 		}
 
 		/*************** Retrive properties ***************/
-		resData, _, _ = rdClient.call(http.MethodGet, "system/configuration", nil, "text/xml")
+		resData, err = rdClient.GetConfiguration(cmd.Context())
+		checkError(err)
 		propertiesFilePath := filepath.Join(os.TempDir(), propertiesFilename)
 		err = os.WriteFile(propertiesFilePath, resData, 0644)
 		if err != nil {
@@ -62,7 +60,8 @@ logs of the server in a ZIP file for further investigation.`,
 		}
 
 		/*************** Retrieve application logs ***************/
-		resData, _, _ = rdClient.call(http.MethodGet, "system/application-logs", nil, "application/zip")
+		resData, err = rdClient.GetApplicationLogs(cmd.Context())
+		checkError(err)
 		logsFilePath := filepath.Join(os.TempDir(), logsFilename)
 		err = os.WriteFile(logsFilePath, resData, 0644)
 		if err != nil {
@@ -86,9 +85,7 @@ logs of the server in a ZIP file for further investigation.`,
 			printStdError("\n%v\n\n", err)
 			os.Exit(1)
 		}
-		if debug {
-			fmt.Printf("[DEBUG] Creating ZIP archive: %v\n", archiveAbsPath)
-		}
+		logger.Debug("Creating ZIP archive", "path", archiveAbsPath)
 		archive, err := os.Create(archiveName)
 		defer archive.Close()
 		if err != nil {
@@ -107,9 +104,7 @@ logs of the server in a ZIP file for further investigation.`,
 			retcode = 1
 			return
 		}
-		if debug {
-			fmt.Printf("[DEBUG] => Including the system info file into the archive: %v\n", sysInfoFile.Name())
-		}
+		logger.Debug("Including the system info file into the archive", "path", sysInfoFile.Name())
 		sysInfoZipWriter, err := archiveWriter.Create(systemInfoFilename)
 		if err != nil {
 			printStdError("\n%v\n\n", err)
@@ -121,9 +116,7 @@ logs of the server in a ZIP file for further investigation.`,
 			retcode = 1
 			return
 		}
-		if debug {
-			fmt.Printf("[DEBUG]    Removing the system info file: %v\n", systemInfoFilePath)
-		}
+		logger.Debug("Removing the system info file", "path", systemInfoFilePath)
 		os.Remove(systemInfoFilePath)
 
 		// ===> Include the properties file
@@ -134,9 +127,7 @@ logs of the server in a ZIP file for further investigation.`,
 			retcode = 1
 			return
 		}
-		if debug {
-			fmt.Printf("[DEBUG] => Including the properties file into the archive: %v\n", propsFile.Name())
-		}
+		logger.Debug("Including the properties file into the archive", "path", propsFile.Name())
 		propsZipWriter, err := archiveWriter.Create(propertiesFilename)
 		if err != nil {
 			printStdError("\n%v\n\n", err)
@@ -148,9 +139,7 @@ logs of the server in a ZIP file for further investigation.`,
 			retcode = 1
 			return
 		}
-		if debug {
-			fmt.Printf("[DEBUG]    Removing the properties file: %v\n", propertiesFilePath)
-		}
+		logger.Debug("Removing the properties file", "path", propertiesFilePath)
 		os.Remove(propertiesFilePath)
 
 		// ===> Include the logs file
@@ -161,13 +150,9 @@ logs of the server in a ZIP file for further investigation.`,
 			retcode = 1
 			return
 		}
-		if debug {
-			fmt.Printf("[DEBUG] Opening the logs ZIP file: %v\n", logsFilePath)
-		}
+		logger.Debug("Opening the logs ZIP file", "path", logsFilePath)
 		for _, zipItem := range zipReader.File {
-			if debug {
-				fmt.Printf("[DEBUG] => Including the logs file into the archive: %v\n", zipItem.Name)
-			}
+			logger.Debug("Including the logs file into the archive", "path", zipItem.Name)
 			zipItemReader, err := zipItem.Open()
 			defer zipItemReader.Close()
 			if err != nil {
@@ -196,9 +181,7 @@ logs of the server in a ZIP file for further investigation.`,
 				return
 			}
 		}
-		if debug {
-			fmt.Printf("[DEBUG]    Removing the logs ZIP file: %v\n", logsFilePath)
-		}
+		logger.Debug("Removing the logs ZIP file", "path", logsFilePath)
 		os.Remove(logsFilePath)
 
 		// Show resulting ZIP file